@@ -1,6 +1,13 @@
 builtin: {
-	cwd:  *"." | string
-	time: *"2006-01-02_15-04-05.000" | string
+	cwd:      *"." | string
+	time:     *"2006-01-02_15-04-05.000" | string
+	os:       *"windows" | string
+	arch:     *"amd64" | string
+	exeDir:   *"." | string
+	home:     *"" | string
+	pathSep:  *"\\" | string
+	hostname: *"" | string
+	env: [string]: string
 }
 
 _versions: {
@@ -82,13 +89,13 @@ tasks: [{
 		"dbdata",
 	]
 	dir: ""
-	platforms: []
+	platform: []
 }, {
 	name: "prune"
 	cmd: ["gpm:prune"]
 	steps: []
 	dir: ""
-	platforms: []
+	platform: []
 }, {
 	name: "dbdata"
 	cmd: [
@@ -97,7 +104,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "bootstrap"
 	cmd: [
@@ -109,7 +116,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "Warframe/OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "clone-server"
 	cmd: [
@@ -123,7 +130,7 @@ tasks: [{
 		"build-server",
 	]
 	dir: "OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "clone-server-data"
 	cmd: [
@@ -134,7 +141,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer/static/data"
-	platforms: []
+	platform: []
 }, {
 	name: "update-server"
 	cmd: [
@@ -149,7 +156,7 @@ tasks: [{
 		"build-server",
 	]
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "update-server:stash"
 	cmd: [
@@ -158,7 +165,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "update-server:checkout"
 	cmd: [
@@ -169,7 +176,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "update-server:pull"
 	cmd: [
@@ -178,7 +185,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer/static/data/0"
-	platforms: []
+	platform: []
 }, {
 	name: "build-server"
 	cmd: [
@@ -188,7 +195,7 @@ tasks: [{
 	]
 	steps: ["build-server:build"]
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "build-server:build"
 	cmd: [
@@ -198,7 +205,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "server"
 	cmd: [
@@ -208,7 +215,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "server:bun"
 	cmd: [
@@ -218,7 +225,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/SpaceNinjaServer"
-	platforms: []
+	platform: []
 }, {
 	name: "database"
 	cmd: [
@@ -228,7 +235,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "database:backup"
 	cmd: [
@@ -237,7 +244,7 @@ tasks: [{
 	]
 	steps: ["database:backup2"]
 	dir: "OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "database:backup2"
 	cmd: [
@@ -248,7 +255,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/backups"
-	platforms: []
+	platform: []
 }, {
 	name: "database:restore"
 	cmd: [
@@ -260,7 +267,7 @@ tasks: [{
 	]
 	steps: ["database:restore2"]
 	dir: "OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "database:restore2"
 	cmd: [
@@ -271,13 +278,14 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF"
-	platforms: []
+	platform: []
 }, {
 	name: "compass"
 	cmd: [_commands.compass]
 	steps: []
 	dir: "OpenWF"
-	platforms: []
+	platform: []
+	when: pulled: ["compass"]
 }, {
 	name: "irc"
 	cmd: [
@@ -286,7 +294,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "OpenWF/Boot/IRC/IRC"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:launcher"
 	cmd: [
@@ -296,7 +304,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "Warframe/Tools"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx11"
 	cmd: [
@@ -311,7 +319,7 @@ tasks: [{
 	steps: []
 	dir:  "Warframe"
 	fork: true
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx12"
 	cmd: [
@@ -326,7 +334,7 @@ tasks: [{
 	steps: []
 	dir:  "Warframe"
 	fork: true
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx11:defrag"
 	cmd: [
@@ -341,7 +349,7 @@ tasks: [{
 	]
 	steps: ["warframe:dx11:contentupdate"]
 	dir: "Warframe"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx12:defrag"
 	cmd: [
@@ -356,7 +364,7 @@ tasks: [{
 	]
 	steps: ["warframe:dx12:contentupdate"]
 	dir: "Warframe"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx11:repair"
 	cmd: [
@@ -370,7 +378,7 @@ tasks: [{
 	]
 	steps: ["warframe:dx11:contentupdate"]
 	dir: "Warframe"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx12:repair"
 	cmd: [
@@ -384,7 +392,7 @@ tasks: [{
 	]
 	steps: ["warframe:dx12:contentupdate"]
 	dir: "Warframe"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx11:contentupdate"
 	cmd: [
@@ -398,7 +406,7 @@ tasks: [{
 	]
 	steps: []
 	dir: "Warframe"
-	platforms: []
+	platform: []
 }, {
 	name: "warframe:dx12:contentupdate"
 	cmd: [
@@ -412,61 +420,62 @@ tasks: [{
 	]
 	steps: []
 	dir: "Warframe"
-	platforms: []
+	platform: []
 }]
 artifacts: {
 	pull: [{
 		url:     "https://github.com/PowerShell/PowerShell/releases/download/v" + _versions.pwshVersion + "/" + _binaries.pwshBinary + ".zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:     "https://github.com/git-for-windows/git/releases/download/v" + _versions.gitVersion + ".windows.1/" + _binaries.gitBinary + ".exe"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 		force: true
 	}, {
 		url:     "https://nodejs.org/dist/v" + _versions.nodeVersion + "/" + _binaries.nodeBinary + ".zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:      "https://github.com/oven-sh/bun/releases/download/bun-v" + _versions.bunVersion + "/" + _binaries.bunBinary + ".zip"
 		dir:      "OpenWF/Downloads/"
 		filename: _binaries.bunBinary + "-" + _versions.bunVersion + ".zip"
 		extract:  "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:     "https://fastdl.mongodb.org/windows/mongodb-windows-x86_64-" + _versions.mongodVersion + ".zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:     "https://fastdl.mongodb.org/tools/db/" + _binaries.mongodbDatabaseToolsBinary + ".zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:     "https://downloads.mongodb.com/compass/" + _binaries.mongoshBinary + ".zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
+		id:      "compass"
 		url:     "https://github.com/mongodb-js/compass/releases/download/v" + _versions.compassVersion + "/" + _binaries.compassBinary + ".zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:     "https://openwf.io/supplementals/IRC.zip"
 		dir:     "OpenWF/Downloads/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}, {
 		url:     "https://openwf.io/supplementals/Download%20Latest%20DLL.ps1"
 		dir:     "Warframe/OpenWF/"
 		extract: "OpenWF/Boot/"
-		platforms: []
+		platform: []
 	}]
 	prune: []
 }
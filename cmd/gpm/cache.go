@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/custom"
+	"github.com/ricochhet/pkg/byteutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+var ErrCacheCorrupt = errors.New("cache contains corrupt artifacts")
+
+// command: cache.
+func cache(cmd string) error {
+	dir, err := config.CacheDir(ctx.Flags.CacheDir)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	c := custom.NewCache(dir)
+
+	switch cmd {
+	case "list":
+		entries, err := c.List()
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		var total int64
+
+		for _, entry := range entries {
+			total += entry.Size
+
+			logutil.Infof(os.Stdout, "%s %10s %s\n",
+				entry.Sha, byteutil.FormatSize(entry.Size), entry.ModTime.Format(time.DateTime))
+		}
+
+		logutil.Infof(os.Stdout, "%d artifacts, %s in %s\n",
+			len(entries), byteutil.FormatSize(total), c.Dir)
+
+		return nil
+	case "prune":
+		removed, err := c.Prune(cacheMaxAge)
+
+		var total int64
+
+		for _, entry := range removed {
+			total += entry.Size
+
+			logutil.Infof(os.Stdout, "Removed: %s (%s)\n", entry.Sha, byteutil.FormatSize(entry.Size))
+		}
+
+		logutil.Infof(os.Stdout, "Reclaimed %s\n", byteutil.FormatSize(total))
+
+		return errutil.WithFrame(err)
+	case "verify":
+		corrupt, err := c.Verify()
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		for _, entry := range corrupt {
+			logutil.Errorf(os.Stdout, "Corrupt: %s\n", entry.Sha)
+		}
+
+		if len(corrupt) != 0 {
+			return ErrCacheCorrupt
+		}
+
+		logutil.Infof(os.Stdout, "Ok: %s\n", c.Dir)
+
+		return nil
+	}
+
+	return errutil.WithFramef("%w: cache %s", ErrUnknownCommand, cmd)
+}
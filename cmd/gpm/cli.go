@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ricochhet/gpm/internal/proc"
+	"github.com/ricochhet/pkg/errutil"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Command is a gpm command. Usage, help and shell completion are generated from the
+// commands, see commandList.
+type Command struct {
+	Name  string
+	Args  string   // Usage of the positional arguments, e.g. '[PROCESS|@GROUP...]'.
+	Desc  string   // Single line description.
+	Notes []string // Further lines of the description.
+
+	Flags  func(fs *flag.FlagSet) // Registers the flags of the command.
+	Global []string               // Global options used by the command, shown in its help.
+	Sub    []*Command             // Subcommands, one of which must be given.
+
+	// Values completes the positional arguments, given the preceding ones.
+	Values func(args []string) []string
+	Run    func(args []string) error
+}
+
+// runCommands are the RPC commands of 'gpm run', and whether they take tasks.
+var runCommands = []struct {
+	name  string
+	tasks bool
+	desc  string
+}{
+	{"start", true, "Start tasks"},
+	{"stop", true, "Stop tasks"},
+	{"stop-all", false, "Stop every task"},
+	{"restart", true, "Restart tasks"},
+	{"restart-all", false, "Restart every task"},
+	{"list", false, "List the tasks"},
+	{"status", true, "Show the status of tasks, and of every agent"},
+	{"attach", true, "Attach to a task running on a pty (pty: true)"},
+}
+
+// startOptions are the global options that configure running tasks.
+var startOptions = []string{
+	"p", "rpc-server", "b", "set-ports", "restart-on-error", "exit-on-error", "exit-on-stop",
+	"logtime", "no-color", "log-format", "pty", "interval", "reverse-on-stop", "inherit-stdin",
+	"env", "env-overload", "token", "controller", "agent-name", "agent-addr",
+}
+
+// commandList returns the commands of gpm, in the order they are shown.
+func commandList() []*Command {
+	run := &Command{
+		Name:   "run",
+		Args:   "COMMAND [PROCESS...]",
+		Desc:   "Run a command, @GROUP expands to its tasks",
+		Notes:  []string{"and AGENT:PROCESS targets an agent"},
+		Global: []string{"p", "token"},
+	}
+
+	for _, rc := range runCommands {
+		sub := &Command{
+			Name: rc.name,
+			Desc: rc.desc,
+			Run: func(args []string) error {
+				return proc.Run(rc.name, args, ctx.Flags.Port, ctx.Flags.Token)
+			},
+		}
+
+		if rc.tasks {
+			sub.Args = "[PROCESS...]"
+			sub.Values = completeTasks
+		}
+
+		run.Sub = append(run.Sub, sub)
+	}
+
+	return []*Command{
+		{
+			Name: "console",
+			Desc: "Start a minimal command console",
+			Run:  func([]string) error { return runConsole() },
+		},
+		{
+			Name:   "cache",
+			Args:   "COMMAND",
+			Desc:   "Manage the shared artifact cache",
+			Global: []string{"cache-dir"},
+			Sub: []*Command{
+				{Name: "list", Desc: "List cached artifacts", Run: cacheCommand("list")},
+				{
+					Name: "prune",
+					Desc: "Remove artifacts unused for a while",
+					Flags: func(fs *flag.FlagSet) {
+						fs.DurationVar(&cacheMaxAge, "max-age", cacheMaxAge,
+							"remove artifacts unused for longer than this")
+					},
+					Run: cacheCommand("prune"),
+				},
+				{Name: "verify", Desc: "Verify the sha of cached artifacts", Run: cacheCommand("verify")},
+			},
+		},
+		{
+			Name:  "check",
+			Desc:  "Validate the Taskfile and show its entries",
+			Notes: []string{"(-json for JSON output, -v lists", "skipped tasks)"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&ctx.Flags.Verbose, "v", ctx.Flags.Verbose, "list skipped tasks")
+				fs.BoolVar(&ctx.Flags.JSON, "json", ctx.Flags.JSON, "use JSON output")
+			},
+			Run: func([]string) error { return checkTaskfile() },
+		},
+		{
+			Name:   "completion",
+			Args:   "SHELL",
+			Desc:   "Print the completion script of the shell",
+			Notes:  []string{"(bash, zsh, fish, powershell)"},
+			Values: func([]string) []string { return shells },
+			Run:    completion,
+		},
+		{
+			Name: "help",
+			Args: "[COMMAND]",
+			Desc: "Show this help, or the help of a command",
+			Values: func(args []string) []string {
+				if len(args) == 0 {
+					return commandNames(commandList())
+				}
+
+				return nil
+			},
+			Run: help,
+		},
+		{
+			Name:   "includes",
+			Args:   "COMMAND",
+			Desc:   "Manage remote includes",
+			Global: []string{"offline"},
+			Sub: []*Command{
+				{
+					Name: "update",
+					Desc: "Download and re-pin remote includes",
+					Run:  func([]string) error { return includes("update") },
+				},
+			},
+		},
+		{
+			Name:  "export",
+			Args:  "FORMAT LOCATION",
+			Desc:  "Export the apps to another process",
+			Notes: []string{"(upstart)"},
+			Values: func(args []string) []string {
+				if len(args) == 0 {
+					return []string{"upstart"}
+				}
+
+				return nil
+			},
+			Run: func(args []string) error {
+				if len(args) != 2 {
+					return help([]string{"export"})
+				}
+
+				return export(args[0], args[1])
+			},
+		},
+		run,
+		{
+			Name:  "pull",
+			Desc:  "Download artifacts, verifying them against",
+			Notes: []string{"Taskfile.lock (-update refreshes it)"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&ctx.Flags.Update, "update", ctx.Flags.Update, "refresh the lockfile")
+			},
+			Global: []string{"optionals", "jobs", "cache-dir", "offline"},
+			Run:    func([]string) error { return pull() },
+		},
+		{
+			Name: "prune",
+			Desc: "Remove files matching the prune rules",
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&ctx.Flags.DryRun, "dry-run", ctx.Flags.DryRun, "only list files that would be removed")
+			},
+			Run: func([]string) error { return prune() },
+		},
+		{
+			Name:   "start",
+			Args:   "[PROCESS|@GROUP...]",
+			Desc:   "Start the application",
+			Global: startOptions,
+			Values: completeTasks,
+			Run: func([]string) error {
+				nc, stop := proc.NotifyCh()
+				defer stop()
+
+				return errutil.WithFrame(ctx.Start(context.Background(), nc, ctx.Flags))
+			},
+		},
+		{
+			Name:   "runas",
+			Args:   "[PROCESS]",
+			Desc:   "Run a runas process",
+			Global: startOptions,
+			Values: completeRunas,
+			Run: func([]string) error {
+				_, err := ctx.Runas(ataskfile.Runas) // Returned boolean is unneeded here.
+
+				return errutil.WithFrame(err)
+			},
+		},
+		{
+			Name: "version",
+			Desc: "Display gpm version",
+			Run: func([]string) error {
+				showVersion()
+
+				return nil
+			},
+		},
+	}
+}
+
+// findCommand returns the command of the name.
+func findCommand(cmds []*Command, name string) *Command {
+	for _, cmd := range cmds {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+// commandNames returns the names of the commands.
+func commandNames(cmds []*Command) []string {
+	names := make([]string, 0, len(cmds))
+
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+	}
+
+	return names
+}
+
+// flagSet returns the flag set of the command.
+func (c *Command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+
+	return fs
+}
+
+// synopsis returns the flags and arguments of the command, e.g. '[-v] [PROCESS...]'.
+func (c *Command) synopsis() string {
+	parts := []string{}
+
+	c.flagSet().VisitAll(func(f *flag.Flag) {
+		name, _ := flag.UnquoteUsage(f)
+		if name == "" {
+			parts = append(parts, "[-"+f.Name+"]")
+		} else {
+			parts = append(parts, "[-"+f.Name+" "+strings.ToUpper(name)+"]")
+		}
+	})
+
+	if c.Args != "" {
+		parts = append(parts, c.Args)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// exec parses the flags of the command, and runs it or its subcommand.
+func (c *Command) exec(args []string) error {
+	fs := c.flagSet()
+	fs.SetOutput(os.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return errutil.New("fs.Parse", err)
+	}
+
+	args = fs.Args()
+
+	if len(c.Sub) == 0 {
+		return c.Run(args)
+	}
+
+	if len(args) == 0 {
+		return commandHelp(c)
+	}
+
+	sub := findCommand(c.Sub, args[0])
+	if sub == nil {
+		return errutil.WithFramef("%w: %s %s", ErrUnknownCommand, c.Name, args[0])
+	}
+
+	return sub.exec(args[1:])
+}
+
+// usage prints the commands and global options of gpm.
+func usage() {
+	fmt.Fprint(os.Stderr, "Tasks:\n")
+
+	for _, cmd := range commandList() {
+		writeCommand(os.Stderr, cmd)
+	}
+
+	fmt.Fprint(os.Stderr, "\nOptions:\n")
+	flag.PrintDefaults()
+
+	if console {
+		return
+	}
+
+	os.Exit(0)
+}
+
+// writeCommand writes the usage line of the command, followed by its notes and
+// subcommands.
+func writeCommand(w io.Writer, cmd *Command) {
+	const indent = "                                       "
+
+	fmt.Fprintf(w, "  %-30s # %s\n", strings.TrimSpace("gpm "+cmd.Name+" "+cmd.synopsis()), cmd.Desc)
+
+	for _, note := range cmd.Notes {
+		fmt.Fprintf(w, "%s%s\n", indent, note)
+	}
+
+	for _, sub := range cmd.Sub {
+		fmt.Fprintf(w, "%s%s\n", indent, strings.TrimSpace(sub.Name+" "+sub.synopsis()))
+	}
+}
+
+// help prints the help of the command, or the usage of gpm without one.
+func help(args []string) error {
+	if len(args) == 0 {
+		usage()
+		return nil
+	}
+
+	cmd := findCommand(commandList(), args[0])
+	if cmd == nil {
+		return errutil.WithFramef("%w: %s", ErrUnknownCommand, args[0])
+	}
+
+	return commandHelp(cmd)
+}
+
+// commandHelp prints the usage, flags and global options used by the command.
+func commandHelp(cmd *Command) error {
+	w := os.Stderr
+
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", strings.TrimSpace("gpm [OPTIONS] "+cmd.Name+" "+cmd.synopsis()), cmd.Desc)
+
+	for _, note := range cmd.Notes {
+		fmt.Fprintf(w, "%s\n", note)
+	}
+
+	if len(cmd.Sub) != 0 {
+		fmt.Fprint(w, "\nCommands:\n")
+
+		for _, sub := range cmd.Sub {
+			fmt.Fprintf(w, "  %-28s # %s\n", strings.TrimSpace(sub.Name+" "+sub.synopsis()), sub.Desc)
+		}
+	}
+
+	fs := cmd.flagSet()
+	fs.SetOutput(w)
+
+	if hasFlags(fs) {
+		fmt.Fprint(w, "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	global := flag.NewFlagSet("gpm", flag.ContinueOnError)
+	global.SetOutput(w)
+
+	for _, name := range slices.Concat(cmd.Global, commonOptions) {
+		if f := flag.Lookup(name); f != nil && global.Lookup(name) == nil {
+			global.Var(f.Value, f.Name, f.Usage)
+		}
+	}
+
+	fmt.Fprint(w, "\nGlobal options, given before the command:\n")
+	global.PrintDefaults()
+
+	return nil
+}
+
+// commonOptions are the global options of every command that reads the Taskfile.
+var commonOptions = []string{"f", "g", "dotfile", "basedir", "var-passes", "debug"}
+
+// hasFlags returns true if any flag is defined in the flag set.
+func hasFlags(fs *flag.FlagSet) bool {
+	has := false
+
+	fs.VisitAll(func(*flag.Flag) {
+		has = true
+	})
+
+	return has
+}
+
+// cacheMaxAge is the -max-age of 'gpm cache prune'.
+var cacheMaxAge = 30 * 24 * time.Hour
+
+// cacheCommand returns the Run function of the cache subcommand.
+func cacheCommand(name string) func([]string) error {
+	return func([]string) error {
+		return cache(name)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+)
+
+var ErrUnknownShell = errors.New("unknown shell")
+
+// completeCommand is the hidden command the completion scripts call, with the words
+// before the cursor and the word being completed: 'gpm __complete run st'.
+const completeCommand = "__complete"
+
+// shells are the shells 'gpm completion' has a script for.
+var shells = []string{"bash", "zsh", "fish", "powershell"}
+
+// flagValues are the values of global options, other options complete to files.
+var flagValues = map[string][]string{
+	"log-format": {"text", "json"},
+	"g":          {dotfileFlag.Name, taskfileFlag.Name, dotfileFlag.Name + taskfileFlag.Name},
+}
+
+// command: completion.
+func completion(args []string) error {
+	if len(args) != 1 {
+		return help([]string{"completion"})
+	}
+
+	var script string
+
+	switch args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	case "powershell":
+		script = powershellCompletion
+	default:
+		return errutil.WithFramef("%w: %s", ErrUnknownShell, args[0])
+	}
+
+	fmt.Fprint(os.Stdout, script)
+
+	return nil
+}
+
+// isCompletion returns true if the command does not need the Taskfile to be read.
+func isCompletion() bool {
+	return len(ctx.Flags.Args) != 0 &&
+		(ctx.Flags.Args[0] == "completion" || ctx.Flags.Args[0] == completeCommand)
+}
+
+// command: __complete. Its arguments are words, not flags of the command.
+func completeWords(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	// Shells that cannot pass an empty argument pass a space instead.
+	words, current := args[:len(args)-1], strings.TrimSpace(args[len(args)-1])
+
+	readCompletionTaskfile(words)
+
+	for _, candidate := range complete(words, current) {
+		fmt.Fprintln(os.Stdout, candidate)
+	}
+
+	return nil
+}
+
+// readCompletionTaskfile reads the Taskfile the words refer to, with -f and -g, without
+// downloading remote includes or reporting errors.
+func readCompletionTaskfile(words []string) {
+	fs := flag.NewFlagSet("gpm", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerFlags(fs, &config.Flags{})
+
+	i, _ := skip(fs, words)
+	_ = fs.Parse(words[:i])
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "f" || f.Name == "g" {
+			_ = flag.Set(f.Name, f.Value.String())
+		}
+	})
+
+	ctx.Flags.Offline = true
+
+	_ = readTaskfile()
+}
+
+// complete returns the candidates for the current word, given the words before it.
+func complete(words []string, current string) []string {
+	var (
+		cmds = commandList()
+		cmd  *Command
+		fs   = flag.CommandLine
+	)
+
+	for {
+		i, pending := skip(fs, words)
+		if pending != nil {
+			return filterPrefix(flagValues[pending.Name], current)
+		}
+
+		if i == len(words) {
+			break
+		}
+
+		switch {
+		case cmd == nil:
+			cmd = findCommand(cmds, words[i])
+		case len(cmd.Sub) != 0:
+			cmd = findCommand(cmd.Sub, words[i])
+		case cmd.Values != nil:
+			return filterPrefix(cmd.Values(words[i:]), current)
+		default:
+			return nil
+		}
+
+		if cmd == nil {
+			return nil
+		}
+
+		fs, words = cmd.flagSet(), words[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(current, "-"):
+		return filterPrefix(flagNames(fs), current)
+	case cmd == nil:
+		return filterPrefix(commandNames(cmds), current)
+	case len(cmd.Sub) != 0:
+		return filterPrefix(commandNames(cmd.Sub), current)
+	case cmd.Values != nil:
+		return filterPrefix(cmd.Values(nil), current)
+	}
+
+	return nil
+}
+
+// skip returns the index of the first positional argument in the words, or their
+// length, and the flag whose value is still to be given, if any.
+func skip(fs *flag.FlagSet, words []string) (int, *flag.Flag) {
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		if word == "--" {
+			return i + 1, nil
+		}
+
+		if !strings.HasPrefix(word, "-") || word == "-" {
+			return i, nil
+		}
+
+		name := strings.TrimLeft(word, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		f := fs.Lookup(name)
+		if f == nil || isBoolFlag(f) {
+			continue
+		}
+
+		if i+1 == len(words) {
+			return len(words), f
+		}
+
+		i++ // Skip the value.
+	}
+
+	return len(words), nil
+}
+
+// isBoolFlag returns true if the flag takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+	return ok && b.IsBoolFlag()
+}
+
+// flagNames returns the names of the flags, with their dash.
+func flagNames(fs *flag.FlagSet) []string {
+	names := []string{}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+
+	return names
+}
+
+// filterPrefix returns the candidates that start with the prefix.
+func filterPrefix(candidates []string, prefix string) []string {
+	return slices.DeleteFunc(slices.Clone(candidates), func(c string) bool {
+		return !strings.HasPrefix(c, prefix)
+	})
+}
+
+// completeTasks returns the tasks, aliases and groups that are not in the args.
+func completeTasks(args []string) []string {
+	names := []string{}
+
+	for _, proc := range ctx.StoredProc.All() {
+		names = append(names, proc.Name)
+		names = append(names, proc.Aliases...)
+	}
+
+	for _, group := range ctx.Groups {
+		names = append(names, config.GroupPrefix+group.Name)
+	}
+
+	return slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(args, name)
+	})
+}
+
+// completeRunas returns the runas names and aliases, for the first argument.
+func completeRunas(args []string) []string {
+	names := []string{}
+
+	if len(args) != 0 {
+		return names
+	}
+
+	for _, runas := range ataskfile.Runas {
+		names = append(names, runas.Name)
+		names = append(names, runas.Aliases...)
+	}
+
+	return names
+}
+
+const bashCompletion = `# bash completion for gpm, generated by 'gpm completion bash'.
+# Load it with: source <(gpm completion bash)
+
+_gpm() {
+	local line="${COMP_LINE:0:COMP_POINT}" cur="" words
+	read -ra words <<< "$line"
+
+	if [[ $line != *[[:space:]] ]]; then
+		cur="${words[-1]}"
+		unset 'words[-1]'
+	fi
+
+	local IFS=$'\n'
+	COMPREPLY=($(gpm __complete "${words[@]:1}" "$cur" 2>/dev/null))
+
+	# Words are split at ':', so complete 'agent:task' past the colon.
+	if [[ $COMP_WORDBREAKS == *:* && $cur == *:* ]]; then
+		local prefix="${cur%"${cur##*:}"}"
+		COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+	fi
+}
+
+complete -o default -F _gpm gpm
+`
+
+const zshCompletion = `#compdef gpm
+# zsh completion for gpm, generated by 'gpm completion zsh'.
+# Load it with: source <(gpm completion zsh)
+
+_gpm() {
+	local -a candidates
+	candidates=("${(@f)$(gpm __complete "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)}")
+	candidates=(${candidates:#})
+
+	if (( ${#candidates} )); then
+		compadd -- "${candidates[@]}"
+	else
+		_files
+	fi
+}
+
+if [[ "$funcstack[1]" == "_gpm" ]]; then
+	_gpm "$@"
+else
+	compdef _gpm gpm
+fi
+`
+
+const fishCompletion = `# fish completion for gpm, generated by 'gpm completion fish'.
+# Load it with: gpm completion fish | source
+
+function __gpm_complete
+	set -l words (commandline -opc)
+	set -e words[1]
+	set -l candidates (gpm __complete $words (commandline -ct) 2>/dev/null)
+
+	if test (count $candidates) -eq 0
+		__fish_complete_path (commandline -ct)
+	else
+		printf '%s\n' $candidates
+	end
+end
+
+complete -c gpm -f -a '(__gpm_complete)'
+`
+
+const powershellCompletion = `# PowerShell completion for gpm, generated by 'gpm completion powershell'.
+# Load it with: gpm completion powershell | Out-String | Invoke-Expression
+
+Register-ArgumentCompleter -Native -CommandName gpm -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+
+	$words = @($commandAst.CommandElements |
+		Where-Object { $_.Extent.EndOffset -le $cursorPosition } |
+		Select-Object -Skip 1 |
+		ForEach-Object { $_.ToString() })
+
+	# An empty argument is not passed to native commands, so pass a space instead.
+	$current = ' '
+	if ($wordToComplete -ne '') {
+		$words = @($words | Select-Object -SkipLast 1)
+		$current = $wordToComplete
+	}
+
+	gpm __complete @words $current 2>$null | ForEach-Object {
+		[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+	}
+}
+`
@@ -5,12 +5,12 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
-	"runtime"
 	"slices"
 	"strings"
-	"sync"
 
 	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/check"
+	"github.com/ricochhet/gpm/internal/ports"
 	"github.com/ricochhet/pkg/cmdutil"
 	"github.com/ricochhet/pkg/cueutil"
 	"github.com/ricochhet/pkg/errutil"
@@ -96,8 +96,20 @@ func readTaskfile() error {
 		return errutil.New("taskfileUnmarshaler.NewFile", err)
 	}
 
+	taskfilePaths = []string{path}
+
+	lockPath := config.LockfilePath(path)
+
+	lock, err := config.ReadLockfile(lockPath)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	pins := len(lock.Includes)
+	update := isIncludesUpdate()
+
 	for _, include := range ataskfile.Includes {
-		pathInclude, err := maybeGlobalTaskfile(include)
+		pathInclude, err := resolveInclude(include, lock, update)
 		if err != nil {
 			return errutil.WithFrame(err)
 		}
@@ -108,43 +120,76 @@ func readTaskfile() error {
 		}
 
 		ataskfile = ataskfile.Merge(taskfile)
+		taskfilePaths = append(taskfilePaths, pathInclude)
+	}
+
+	if update || len(lock.Includes) != pins {
+		if err := lock.Write(lockPath); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
+	pluginsDir, err := config.PluginsDir(ctx.Flags.PluginsDir)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	stateDir, err := config.StateDir(ctx.Flags.CacheDir, path)
+	if err != nil {
+		return errutil.WithFrame(err)
 	}
 
 	mu.Lock()
 	defer mu.Unlock()
 
+	ctx.Groups = ataskfile.Groups
+	ctx.Builtins.SetPlugins(ataskfile.Builtins, pluginsDir)
 	ctx.Builtins.SetArtifacts(ataskfile.Artifacts)
+	ctx.Builtins.SetLockfile(lockPath)
+	ctx.States.SetPath(filepath.Join(stateDir, "procs.cue"))
 
-	index := 0
+	allocator, allocatorPath, err := readPorts(stateDir)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	index, portIndex := 0, 0
+	skipped = nil
+
+	// The ports of processes that will be re-adopted are in use by those processes.
+	running := map[string]config.ProcState{}
+	if isStart() {
+		running = ctx.States.Running()
+	}
 
 	for _, task := range ataskfile.Tasks {
-		if len(task.Platforms) != 0 && !slices.Contains(task.Platforms, runtime.GOOS) {
+		name := strings.TrimSpace(task.Name)
+
+		if reason := skipReason(task); reason != "" {
+			skipped = append(skipped, check.Skipped{Task: name, Reason: reason})
+			logutil.Debugf(os.Stdout, "skipping task %s: %s\n", name, reason)
+
 			continue
 		}
 
-		name := strings.TrimSpace(task.Name)
-
-		proc := &config.ProcInfo{
-			Name:           name,
-			Desc:           task.Desc,
-			Aliases:        task.Aliases,
-			Cmdline:        task.Cmd,
-			Env:            ataskfile.Env,
-			Steps:          task.Steps,
-			Dir:            task.Dir,
-			Fork:           task.Fork,
-			Silent:         task.Silent,
-			ColorIndex:     index,
-			RestartOnError: ctx.Flags.RestartOnError,
-			InheritStdin:   ctx.Flags.InheritStdin,
+		// Invalid patterns are reported by the linter when checking.
+		proc, err := config.NewProcInfo(task, ataskfile.Env, ctx.Flags, index)
+		if err != nil && !isCheck() {
+			return errutil.New("task %s", err, name)
 		}
+
 		if ctx.Flags.SetPorts {
-			proc.SetPort = true
-			proc.Port = ctx.Flags.BasePort
-			ctx.Flags.BasePort += 100
+			if entry, ok := running[name]; ok && entry.CmdHash == proc.CmdHash() {
+				allocator.Keep(name)
+			}
+
+			if err := proc.AssignPorts(allocator, portIndex, task.Ports); err != nil {
+				return errutil.WithFrame(err)
+			}
+
+			portIndex++
 		}
 
-		proc.Cond = sync.NewCond(&proc.Mu)
 		ctx.SharedProc.Add(proc)
 
 		if len(name) > ctx.MaxProcNameLength {
@@ -158,11 +203,48 @@ func readTaskfile() error {
 		return errors.New("no valid entry")
 	}
 
+	if persistPorts() {
+		if err := allocator.Write(allocatorPath); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
 	ctx.StoredProc.CopyFrom(ctx.SharedProc)
 
 	return nil
 }
 
+// isCheck returns true if the command is 'check'.
+func isCheck() bool {
+	return len(ctx.Flags.Args) != 0 && ctx.Flags.Args[0] == "check"
+}
+
+// readPorts reads the port assignments kept in the state directory. Persisted ports
+// are only checked for availability by commands that start processes.
+func readPorts(dir string) (*ports.Allocator, string, error) {
+	verify := isStart()
+	portsPath := filepath.Join(dir, "ports.cue")
+
+	allocator, err := ports.Read(portsPath, ctx.Flags.BasePort, verify)
+	if err != nil {
+		return nil, "", errutil.WithFrame(err)
+	}
+
+	return allocator, portsPath, nil
+}
+
+// isStart returns true if the command starts processes, or runs a runas process.
+func isStart() bool {
+	return len(ctx.Flags.Args) == 0 || slices.Contains([]string{"start", "runas"}, ctx.Flags.Args[0])
+}
+
+// persistPorts returns true if the command keeps the ports it assigns, for the
+// commands that start processes, and export. Other commands, such as check or
+// completion, only read the assignments.
+func persistPorts() bool {
+	return isStart() || ctx.Flags.Args[0] == "export"
+}
+
 // maybeGlobalTaskfile returns the path of the taskfile file to use.
 // Global taskfile is set next to the executable, regardless of the working directory.
 func maybeGlobalTaskfile(taskfile string) (string, error) {
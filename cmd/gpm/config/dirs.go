@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+// CacheDir returns the directory used to cache downloads. If dir is empty,
+// the default directory within the user cache directory is returned.
+func CacheDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errutil.New("os.UserCacheDir", err)
+	}
+
+	return filepath.Join(dir, "gpm"), nil
+}
+
+// PluginsDir returns the directory searched for plugin executables. If dir is
+// empty, the default directory within the user config directory is returned.
+func PluginsDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errutil.New("os.UserConfigDir", err)
+	}
+
+	return filepath.Join(dir, "gpm", "plugins"), nil
+}
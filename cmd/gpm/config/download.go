@@ -0,0 +1,87 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ricochhet/pkg/dlutil"
+	"github.com/ricochhet/pkg/errutil"
+)
+
+// DownloadOptions configures the HTTP client used for artifacts and remote includes.
+type DownloadOptions struct {
+	Timeout        string   `json:"timeout"`
+	ConnectTimeout string   `json:"connectTimeout"`
+	Proxy          string   `json:"proxy"`
+	CAFiles        []string `json:"caFiles"`
+	// Headers are added to requests by host name. Values are expanded with
+	// environment variables, e.g. "Bearer ${ARTIFACT_TOKEN}".
+	Headers   map[string]map[string]string `json:"headers"`
+	Netrc     bool                         `json:"netrc"`
+	NetrcFile string                       `json:"netrcFile"` // Defaults to $NETRC, or ~/.netrc.
+	// PublicKeys are base64 ed25519 keys that artifact signatures are verified against.
+	PublicKeys []string `json:"publicKeys"`
+}
+
+// Client creates the http.Client described by the receiver.
+func (o DownloadOptions) Client() (*http.Client, error) {
+	opts := dlutil.ClientOptions{
+		Proxy:   o.Proxy,
+		CAFiles: o.CAFiles,
+		Headers: map[string]map[string]string{},
+	}
+
+	var err error
+
+	if o.Timeout != "" {
+		if opts.Timeout, err = time.ParseDuration(o.Timeout); err != nil {
+			return nil, errutil.New("time.ParseDuration", err)
+		}
+	}
+
+	if o.ConnectTimeout != "" {
+		if opts.ConnectTimeout, err = time.ParseDuration(o.ConnectTimeout); err != nil {
+			return nil, errutil.New("time.ParseDuration", err)
+		}
+	}
+
+	for host, headers := range o.Headers {
+		opts.Headers[host] = map[string]string{}
+		for key, value := range headers {
+			opts.Headers[host][key] = os.ExpandEnv(value)
+		}
+	}
+
+	if o.Netrc {
+		if opts.Netrc, err = netrcPath(o.NetrcFile); err != nil {
+			return nil, errutil.WithFrame(err)
+		}
+	}
+
+	client, err := dlutil.NewClient(opts)
+	if err != nil {
+		return nil, errutil.New("dlutil.NewClient", err)
+	}
+
+	return client, nil
+}
+
+// netrcPath returns the path of the netrc file to use.
+func netrcPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	if path, ok := os.LookupEnv("NETRC"); ok {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errutil.New("os.UserHomeDir", err)
+	}
+
+	return filepath.Join(home, ".netrc"), nil
+}
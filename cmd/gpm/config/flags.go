@@ -20,18 +20,36 @@ type Flags struct {
 	ExitOnError    bool   `json:"exitOnError"`
 	ExitOnStop     bool   `json:"exitOnStop"`
 	LogTime        bool   `json:"logTime"`
+	NoColor        bool   `json:"noColor"`
+	LogFormat      string `json:"logFormat"`
 	Pty            bool   `json:"pty"`
 	Interval       uint   `json:"interval"`
 	ReverseOnStop  bool   `json:"reverseOnStop"`
 	InheritStdin   bool   `json:"inheritStdin"`
 	// Internals.
-	Args      []string `json:"args"`
-	Envfiles  []string `json:"envfiles"`
-	VarPasses int      `json:"vPasses"`
-	Global    string   `json:"global"`
-	Debug     bool     `json:"debug"`
-	QuickEdit bool     `json:"quickEdit"` // noop on non-Windows systems.
-	Optionals bool     `json:"optionals"`
+	Args       []string `json:"args"`
+	Envfiles   []string `json:"envfiles"`
+	VarPasses  int      `json:"vPasses"`
+	Global     string   `json:"global"`
+	Debug      bool     `json:"debug"`
+	QuickEdit  bool     `json:"quickEdit"` // noop on non-Windows systems.
+	Optionals  bool     `json:"optionals"`
+	JSON       bool     `json:"json"`
+	Offline    bool     `json:"offline"`
+	Update     bool     `json:"update"`
+	Jobs       int      `json:"jobs"`
+	CacheDir   string   `json:"cacheDir"`
+	DryRun     bool     `json:"dryRun"`
+	PluginsDir string   `json:"pluginsDir"`
+	Verbose    bool     `json:"verbose"`
+	// Mirrors rewrites artifact URL prefixes, e.g. to an internal mirror.
+	Mirrors  map[string]string `json:"mirrors"`
+	Download DownloadOptions   `json:"download"`
+	// Distributed mode, where agents register with a controller.
+	Token      string `json:"token"`      // Authenticates RPC connections, see RPCToken.
+	Controller string `json:"controller"` // RPC address of the controller to register with.
+	AgentName  string `json:"agentName"`  // Name of the agent, the hostname by default.
+	AgentAddr  string `json:"agentAddr"`  // RPC address the controller reaches the agent at.
 }
 
 // DefaultServer returns the default RPC address:port.
@@ -47,6 +65,16 @@ func DefaultServer(serverPort uint) string {
 	return fmt.Sprintf("127.0.0.1:%d", serverPort)
 }
 
+// RPCToken returns the token RPC connections are authenticated with, GPM_RPC_TOKEN if
+// no token is set.
+func RPCToken(token string) string {
+	if token != "" {
+		return token
+	}
+
+	return os.Getenv("GPM_RPC_TOKEN")
+}
+
 // DefaultAddr returns the default RPC address.
 func DefaultAddr() string {
 	if s, ok := os.LookupEnv("GPM_RPC_ADDR"); ok {
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/maputil"
+)
+
+// GroupPrefix refers to a group rather than a task, e.g. '@dev'.
+const GroupPrefix = "@"
+
+var (
+	ErrUnknownGroup = errors.New("unknown group")
+	ErrGroupCycle   = errors.New("group includes itself")
+)
+
+// Group is a named set of tasks, such as a profile. Tasks may include other groups
+// by their '@' name. The flags of a group override the global flags when it is started
+// from the command line. Started over RPC, only the restartOnError, inheritStdin and
+// pty flags apply, to the tasks being started. Ports are assigned when the taskfile
+// is read, so setPorts and baseport cannot be set by a group.
+type Group struct {
+	Flags `json:",inline"`
+
+	Name  string   `json:"name"`
+	Desc  string   `json:"desc"`
+	Tasks []string `json:"tasks"`
+}
+
+type Groups []Group
+
+// IsGroup returns true if the name refers to a group.
+func IsGroup(name string) bool {
+	return strings.HasPrefix(name, GroupPrefix)
+}
+
+// Expand returns the names with every group replaced by its tasks, without duplicates,
+// and the flags of the expanded groups, or nil if none set any. The flags of a group
+// override those of the groups it includes.
+func (g Groups) Expand(names []string) ([]string, *Flags, error) {
+	var (
+		tasks []string
+		flags *Flags
+	)
+
+	for _, name := range names {
+		expanded, err := g.expand(name, &flags, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, task := range expanded {
+			if !slices.Contains(tasks, task) {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+
+	return tasks, flags, nil
+}
+
+// expand expands the name, merging the flags of every group into flags. The stack
+// holds the groups being expanded, to detect cycles.
+func (g Groups) expand(name string, flags **Flags, stack []string) ([]string, error) {
+	if !IsGroup(name) {
+		return []string{name}, nil
+	}
+
+	name = strings.TrimPrefix(name, GroupPrefix)
+	if slices.Contains(stack, name) {
+		return nil, errutil.WithFramef("%w: %s", ErrGroupCycle, strings.Join(append(stack, name), " -> "))
+	}
+
+	i := slices.IndexFunc(g, func(group Group) bool {
+		return group.Name == name
+	})
+	if i < 0 {
+		return nil, errutil.WithFramef("%w: %s", ErrUnknownGroup, name)
+	}
+
+	var tasks []string
+
+	for _, task := range g[i].Tasks {
+		expanded, err := g.expand(task, flags, append(slices.Clone(stack), name))
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, expanded...)
+	}
+
+	if !reflect.ValueOf(g[i].Flags).IsZero() {
+		if *flags == nil {
+			*flags = &Flags{}
+		}
+
+		*flags = maputil.Merge(*flags, &g[i].Flags, "json", true)
+	}
+
+	return tasks, nil
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ricochhet/pkg/cueutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+type Lockfile struct {
+	Includes  []LockedInclude  `json:"includes"`
+	Artifacts []LockedArtifact `json:"artifacts"`
+
+	mu sync.Mutex
+}
+
+type LockedInclude struct {
+	URL string `json:"url"`
+	Sha string `json:"sha"`
+}
+
+type LockedArtifact struct {
+	ID       string `json:"id,omitempty"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Sha      string `json:"sha"`
+}
+
+// LockfilePath returns the path of the lockfile that belongs to the taskfile.
+func LockfilePath(taskfile string) string {
+	return strings.TrimSuffix(taskfile, filepath.Ext(taskfile)) + ".lock"
+}
+
+// ReadLockfile reads the lockfile, returning an empty Lockfile if it does not exist.
+func ReadLockfile(path string) (*Lockfile, error) {
+	lock := &Lockfile{}
+	if !fsutil.Exists(path) {
+		return lock, nil
+	}
+
+	if _, err := cueutil.NewDefaultUnmarshal[Lockfile]().File(path, lock); err != nil {
+		return nil, errutil.New("cueutil.File", err)
+	}
+
+	return lock, nil
+}
+
+// Write writes the receiver to the specified path.
+func (l *Lockfile) Write(path string) error {
+	if err := cueutil.NewDefaultMarshal[*Lockfile]().File(path, l); err != nil {
+		return errutil.New("cueutil.File", err)
+	}
+
+	logutil.Debugf(os.Stdout, "wrote lockfile: %s\n", path)
+
+	return nil
+}
+
+// Include returns the locked include by url.
+func (l *Lockfile) Include(url string) (LockedInclude, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := slices.IndexFunc(l.Includes, func(li LockedInclude) bool {
+		return li.URL == url
+	})
+	if i == -1 {
+		return LockedInclude{}, false
+	}
+
+	return l.Includes[i], true
+}
+
+// SetInclude adds or replaces the locked include by url.
+func (l *Lockfile) SetInclude(include LockedInclude) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := slices.IndexFunc(l.Includes, func(li LockedInclude) bool {
+		return li.URL == include.URL
+	})
+	if i == -1 {
+		l.Includes = append(l.Includes, include)
+		return
+	}
+
+	l.Includes[i] = include
+}
+
+// Key returns the ID of the locked artifact, falling back to its URL.
+func (la LockedArtifact) Key() string {
+	if la.ID != "" {
+		return la.ID
+	}
+
+	return la.URL
+}
+
+// Matches returns true if the receiver and the target are the same file, regardless
+// of the URL it was downloaded from.
+func (la LockedArtifact) Matches(target LockedArtifact) bool {
+	return la.Filename == target.Filename && la.Size == target.Size &&
+		strings.EqualFold(la.Sha, target.Sha)
+}
+
+// Artifact returns the locked artifact by key, see config.Download.Key.
+func (l *Lockfile) Artifact(key string) (LockedArtifact, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := slices.IndexFunc(l.Artifacts, func(la LockedArtifact) bool {
+		return la.Key() == key
+	})
+	if i == -1 {
+		return LockedArtifact{}, false
+	}
+
+	return l.Artifacts[i], true
+}
+
+// SetArtifact adds or replaces the locked artifact by key.
+func (l *Lockfile) SetArtifact(artifact LockedArtifact) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := slices.IndexFunc(l.Artifacts, func(la LockedArtifact) bool {
+		return la.Key() == artifact.Key()
+	})
+	if i == -1 {
+		l.Artifacts = append(l.Artifacts, artifact)
+		return
+	}
+
+	l.Artifacts[i] = artifact
+}
@@ -1,9 +1,22 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
 	"os/exec"
+	"regexp"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ricochhet/gpm/internal/ports"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
 )
 
 type ProcInfo struct {
@@ -17,6 +30,7 @@ type ProcInfo struct {
 	Dir        string
 	Fork       bool
 	Port       uint
+	Ports      map[string]uint // By environment variable name, including 'PORT'.
 	Silent     bool
 	SetPort    bool
 	ColorIndex int
@@ -26,10 +40,188 @@ type ProcInfo struct {
 	StoppedBySupervisor bool
 	RestartOnError      bool
 	InheritStdin        bool
+	Pty                 bool
+
+	// Readiness, see Task.
+	ReadyWhen    []*regexp.Regexp
+	ReadyTimeout time.Duration
+	ErrorWhen    []*regexp.Regexp
+	WaitFor      []string
+	Readiness    *Readiness
+	Output       logutil.Output
+
+	// Output streams, see Task.
+	Stdout          string
+	Stderr          string
+	ErrorWhenStderr []*regexp.Regexp
 
 	Mu      sync.Mutex
 	Cond    *sync.Cond
 	WaitErr error
+	running atomic.Bool // Cmd != nil, for readers that do not hold Mu.
+}
+
+// SetCmd sets the running command of the proc, nil once it exited. Mu must be held.
+func (p *ProcInfo) SetCmd(cmd *exec.Cmd) {
+	p.Cmd = cmd
+	p.running.Store(cmd != nil)
+}
+
+// ApplyFlags enables the options of the proc set by the flags of a started group.
+// Unset flags are not told apart from false ones, so options are only enabled.
+func (p *ProcInfo) ApplyFlags(flags *Flags) {
+	p.Mu.Lock()
+	defer p.Mu.Unlock()
+
+	p.RestartOnError = p.RestartOnError || flags.RestartOnError
+	p.InheritStdin = p.InheritStdin || flags.InheritStdin
+	p.Pty = p.Pty || flags.Pty
+}
+
+// Running returns true if the proc has a running command. Unlike reading Cmd, it does
+// not require holding Mu, which is held while a proc starts.
+func (p *ProcInfo) Running() bool {
+	return p.running.Load()
+}
+
+// PortEnv returns the ports of the proc as sorted 'NAME=port' pairs.
+func (p *ProcInfo) PortEnv() []string {
+	env := make([]string, 0, len(p.Ports))
+	for _, key := range slices.Sorted(maps.Keys(p.Ports)) {
+		env = append(env, fmt.Sprintf("%s=%d", key, p.Ports[key]))
+	}
+
+	return env
+}
+
+// CmdHash returns a hash of the command line and directory of the proc, used to
+// check if a running process was started with the same command.
+func (p *ProcInfo) CmdHash() string {
+	sum := sha256.Sum256([]byte(p.Dir + "\x00" + strings.Join(p.Cmdline, "\x00")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// NewProcInfo returns the proc of the task, as started with the flags. Env is the
+// environment of the taskfile, and colorIndex the color of the task output. Ports are
+// not assigned, see AssignPorts. If the readiness or output patterns of the task are
+// invalid, the proc is returned without them along with the error, so the task can
+// still be checked.
+func NewProcInfo(task Task, env map[string][]string, flags *Flags, colorIndex int) (*ProcInfo, error) {
+	proc := &ProcInfo{
+		Name:           strings.TrimSpace(task.Name),
+		Desc:           task.Desc,
+		Aliases:        task.Aliases,
+		Cmdline:        task.Cmd,
+		Env:            env,
+		Steps:          task.Steps,
+		Dir:            task.Dir,
+		Fork:           task.Fork,
+		Silent:         task.Silent,
+		ColorIndex:     colorIndex,
+		RestartOnError: flags.RestartOnError,
+		InheritStdin:   flags.InheritStdin,
+		Pty:            task.Pty || flags.Pty,
+		WaitFor:        task.WaitFor,
+		Readiness:      NewReadiness(),
+
+		Stdout: task.Stdout,
+		Stderr: task.Stderr,
+	}
+	proc.Cond = sync.NewCond(&proc.Mu)
+
+	readinessErr := proc.compileReadiness(task)
+
+	output, outputErr := compileOutput(task.Output)
+	proc.Output = output
+
+	return proc, errors.Join(readinessErr, outputErr)
+}
+
+// AssignPorts assigns the ports of the proc, the index-th of the procs with ports.
+func (p *ProcInfo) AssignPorts(allocator *ports.Allocator, index int, names []string) error {
+	assigned, err := allocator.Allocate(p.Name, index, names)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	p.Ports = assigned
+	p.SetPort = true
+	p.Port = assigned[ports.EnvName("")]
+
+	return nil
+}
+
+// compileReadiness compiles the readiness patterns, and parses the ready timeout of
+// the task. Nothing is set if any is invalid.
+func (p *ProcInfo) compileReadiness(task Task) error {
+	readyWhen, err := compilePatterns(task.ReadyWhen)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	errorWhen, err := compilePatterns(task.ErrorWhen)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	errorWhenStderr, err := compilePatterns(task.ErrorWhenStderr)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	var timeout time.Duration
+
+	if task.ReadyTimeout != "" {
+		if timeout, err = time.ParseDuration(task.ReadyTimeout); err != nil {
+			return errutil.New("time.ParseDuration", err)
+		}
+	}
+
+	p.ReadyWhen, p.ErrorWhen, p.ErrorWhenStderr, p.ReadyTimeout = readyWhen, errorWhen, errorWhenStderr, timeout
+
+	return nil
+}
+
+// compileOutput compiles the output options of a task.
+func compileOutput(o Output) (logutil.Output, error) {
+	output := logutil.Output{
+		JSON:   o.JSON,
+		Fields: o.Fields,
+		Level:  o.Level,
+	}
+
+	if output.JSON && output.Level == "" {
+		output.Level = "level"
+	}
+
+	var err error
+
+	if output.Filter, err = compilePatterns(o.Filter); err != nil {
+		return logutil.Output{}, errutil.WithFrame(err)
+	}
+
+	if output.Highlight, err = compilePatterns(o.Highlight); err != nil {
+		return logutil.Output{}, errutil.WithFrame(err)
+	}
+
+	return output, nil
+}
+
+// compilePatterns compiles every pattern.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errutil.New("regexp.Compile", err)
+		}
+
+		res = append(res, re)
+	}
+
+	return res, nil
 }
 
 type ProcManager struct {
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+type ReadyState string
+
+const (
+	Starting ReadyState = "starting"
+	Ready    ReadyState = "ready"
+	NotReady ReadyState = "failed"
+)
+
+// Readiness tracks whether the running process of a proc is ready.
+type Readiness struct {
+	mu     sync.Mutex
+	state  ReadyState    // Empty if the proc is not running.
+	result ReadyState    // The state the last start settled on.
+	exited bool          // The proc exited, and will not be restarted.
+	done   chan struct{} // Closed when a start settles.
+}
+
+// NewReadiness returns a Readiness of a proc that is not running.
+func NewReadiness() *Readiness {
+	return &Readiness{done: make(chan struct{})}
+}
+
+// Start marks the proc as starting.
+func (r *Readiness) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state = Starting
+	r.exited = false
+}
+
+// Set settles a starting proc as Ready or NotReady. It returns false if the proc
+// was not starting.
+func (r *Readiness) Set(state ReadyState) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != Starting {
+		return false
+	}
+
+	r.state = state
+	r.settle(state)
+
+	return true
+}
+
+// Stop marks the proc as not running, settling it as NotReady if it was starting.
+func (r *Readiness) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == Starting {
+		r.settle(NotReady)
+	}
+
+	r.state = ""
+}
+
+// Exit marks the proc as not running once it will not be restarted. After a clean
+// exit, the state the proc settled on is kept, otherwise it settles as NotReady.
+// Waiting for an exited proc returns that state, rather than waiting for a next start.
+func (r *Readiness) Exit(clean bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.result
+	if r.state == Starting || !clean {
+		state = NotReady
+	}
+
+	r.state = ""
+	r.exited = true
+	r.settle(state)
+}
+
+// State returns the state of the proc.
+func (r *Readiness) State() ReadyState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.state
+}
+
+// Wait returns the state of a running proc once it has settled. If the proc is not
+// running, Wait waits for its next start to settle, or returns the state an exited
+// proc settled on. If timeout is positive, NotReady is returned once it elapses.
+func (r *Readiness) Wait(timeout time.Duration) ReadyState {
+	r.mu.Lock()
+
+	switch {
+	case r.state == Ready || r.state == NotReady:
+		defer r.mu.Unlock()
+		return r.state
+	case r.state == "" && r.exited:
+		defer r.mu.Unlock()
+		return r.result
+	}
+
+	done := r.done
+	r.mu.Unlock()
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+			return NotReady
+		}
+	} else {
+		<-done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.result
+}
+
+// settle wakes every waiter with the state.
+func (r *Readiness) settle(state ReadyState) {
+	r.result = state
+	close(r.done)
+	r.done = make(chan struct{})
+}
@@ -0,0 +1,190 @@
+// Schema for gpm Taskfiles, mirroring config.Taskfile.
+//
+// Validate a Taskfile with `gpm check`, or unify it with #Taskfile directly.
+
+#Taskfile: {
+	builtin?: #Values
+	includes?: [...string]
+	env?: [string]: [...string]
+	runas?: [...#Runas]
+	tasks?: [...#Task]
+	artifacts?: #Artifacts
+	builtins?: [...#Builtin]
+	groups?: [...#Group]
+}
+
+// Values injected by gpm, see cueutil.Builtins.
+#Values: {
+	args?: [...]
+	cwd?:      string
+	time?:     string
+	os?:       string
+	arch?:     string
+	exeDir?:   string
+	home?:     string
+	pathSep?:  string
+	hostname?: string
+	env?: [string]: string
+}
+
+#Flags: {
+	taskfile?:       string
+	dotfile?:        string
+	envfile?:        string
+	envOverload?:    bool
+	port?:           int & >=0
+	startRpcServer?: bool
+	basedir?:        string
+	baseport?:       int & >=0
+	setPorts?:       bool
+	restartOnError?: bool
+	exitOnError?:    bool
+	exitOnStop?:     bool
+	logTime?:        bool
+	noColor?:        bool
+	logFormat?:      "text" | "json"
+	pty?:            bool
+	interval?:       int & >=0
+	reverseOnStop?:  bool
+	inheritStdin?:   bool
+	args?: [...string]
+	envfiles?: [...string]
+	vPasses?:    int
+	global?:     string
+	debug?:      bool
+	quickEdit?:  bool
+	optionals?:  bool
+	json?:       bool
+	offline?:    bool
+	update?:     bool
+	jobs?:       int & >=0
+	cacheDir?:   string
+	dryRun?:     bool
+	pluginsDir?: string
+	verbose?:    bool
+	mirrors?: [string]: string
+	download?: #DownloadOptions
+
+	// Distributed mode, where agents register with a controller.
+	token?:      string
+	controller?: string
+	agentName?:  string
+	agentAddr?:  string
+}
+
+#DownloadOptions: {
+	timeout?:        string
+	connectTimeout?: string
+	proxy?:          string
+	caFiles?: [...string]
+	headers?: [string]: [string]: string
+	netrc?:     bool
+	netrcFile?: string
+	publicKeys?: [...string]
+}
+
+#Runas: {
+	#Flags
+
+	name!: string
+	aliases?: [...string]
+	tasks?: [...string]
+	start?: bool
+}
+
+#Group: {
+	#Flags
+
+	name!: string & !~"^@"
+	desc?: string
+	tasks!: [...string]
+}
+
+#Task: {
+	#Flags
+
+	name!:  string
+	desc?:  string
+	aliases?: [...string]
+	cmd?: [...string]
+	steps?: [...string]
+	dir?:    string
+	fork?:   bool
+	silent?: bool
+	platform?: [...string]
+	ports?: [...=~"^[A-Za-z0-9_]+$"]
+
+	// Readiness, matched against the output of the task.
+	readyWhen?: [...string]
+	readyTimeout?: string
+	errorWhen?: [...string]
+	waitFor?: [...string]
+	when?: #When
+	output?: #Output
+
+	// Output streams, "discard" or a file the stream is appended to.
+	stdout?: string
+	stderr?: string
+	errorWhenStderr?: [...string]
+}
+
+#Output: {
+	json?: bool
+	fields?: [...string]
+	level?: string
+	filter?: [...string]
+	highlight?: [...string]
+}
+
+#When: {
+	if?: bool
+	exists?: [...string]
+	env?: [...string]
+	flags?: [...string]
+	pulled?: [...string]
+}
+
+#Download: {
+	id?:       string
+	url!:      string
+	mirrors?: [...string]
+	sha?:      =~"^((sha256|sha512):)?[0-9a-fA-F]+$"
+	dir?:      string
+	filename?: string
+	extract?:  string
+	platform?: [...string]
+	optional?: bool
+	force?:    bool
+
+	// A checksum file, used if sha is empty, and a detached ed25519 signature.
+	shaUrl?:    string
+	signature?: string
+	publicKeys?: [...string]
+
+	// Post-extract actions, paths are relative to the extraction directory.
+	strip?: int & >=0
+	move?: [...{from!: string, to!: string}]
+	chmod?: [...string]
+	symlink?: [...{target!: string, link!: string}]
+	deleteArchive?: bool
+}
+
+#File: {
+	name!: string
+	sha?:  string
+	exclude?: [...string]
+	keep?:   int & >=0
+	maxAge?: string
+}
+
+#Builtin: {
+	name!: string & !~"^gpm:"
+	desc?: string
+	cmd!: [string, ...string]
+	dir?: string
+}
+
+#Artifacts: {
+	pull?: [...#Download]
+	prune?: [...#File]
+}
@@ -0,0 +1,11 @@
+package config
+
+import _ "embed"
+
+// Schema is the CUE schema describing a Taskfile.
+//
+//go:embed schema.cue
+var Schema []byte
+
+// SchemaDefinition is the definition within Schema that a Taskfile is validated against.
+const SchemaDefinition = "#Taskfile"
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/ricochhet/pkg/cueutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// State records the processes started by the supervisor, so a later supervisor can
+// re-adopt them if it was killed.
+type State struct {
+	Procs map[string]ProcState `json:"procs"`
+}
+
+type ProcState struct {
+	Pid       int    `json:"pid"`
+	Pgid      int    `json:"pgid"`
+	StartTime uint64 `json:"startTime"` // In clock ticks since boot, see proc(5).
+	CmdHash   string `json:"cmdHash"`
+}
+
+// StateDir returns the directory machine-local state of the taskfile is kept in,
+// such as port assignments. It is keyed by the absolute path of the taskfile.
+func StateDir(cacheDir, taskfile string) (string, error) {
+	dir, err := CacheDir(cacheDir)
+	if err != nil {
+		return "", errutil.WithFrame(err)
+	}
+
+	abs, err := filepath.Abs(taskfile)
+	if err != nil {
+		return "", errutil.New("filepath.Abs", err)
+	}
+
+	key := sha256.Sum256([]byte(abs))
+
+	return filepath.Join(dir, "state", hex.EncodeToString(key[:8])), nil
+}
+
+// ReadState reads the state file, returning an empty State if it does not exist.
+func ReadState(path string) (*State, error) {
+	state := &State{}
+
+	if fsutil.Exists(path) {
+		if _, err := cueutil.NewDefaultUnmarshal[State]().File(path, state); err != nil {
+			return nil, errutil.New("cueutil.File", err)
+		}
+	}
+
+	if state.Procs == nil {
+		state.Procs = map[string]ProcState{}
+	}
+
+	return state, nil
+}
+
+// Write writes the receiver to the specified path.
+func (s *State) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errutil.New("os.MkdirAll", err)
+	}
+
+	if err := cueutil.NewDefaultMarshal[*State]().File(path, s); err != nil {
+		return errutil.New("cueutil.File", err)
+	}
+
+	logutil.Debugf(os.Stdout, "wrote state: %s\n", path)
+
+	return nil
+}
@@ -8,6 +8,8 @@ type Taskfile struct {
 	Runas     []Runas             `json:"runas"`
 	Tasks     []Task              `json:"tasks"`
 	Artifacts Artifacts           `json:"artifacts"`
+	Builtins  []Builtin           `json:"builtins"`
+	Groups    Groups              `json:"groups"`
 }
 
 type Runas struct {
@@ -31,22 +33,92 @@ type Task struct {
 	Fork      bool     `json:"fork"`
 	Silent    bool     `json:"silent"`
 	Platforms []string `json:"platform"`
+	Ports     []string `json:"ports"` // Named ports, exposed as 'PORT_<NAME>'.
+	// Readiness, matched against the output of the task. A task is ready once a
+	// readyWhen pattern matches, or once started if it has none.
+	ReadyWhen    []string `json:"readyWhen"`
+	ReadyTimeout string   `json:"readyTimeout"` // Fail the task if not ready within this duration.
+	ErrorWhen    []string `json:"errorWhen"`    // Fail the task on a match, even if it exits with 0.
+	WaitFor      []string `json:"waitFor"`      // Tasks that must be ready before this task starts.
+	When         When     `json:"when"`
+	Output       Output   `json:"output"`
+	// Output streams. Stdout and Stderr redirect a stream, 'discard' or a file the
+	// stream is appended to. Silent discards both.
+	Stdout          string   `json:"stdout"`
+	Stderr          string   `json:"stderr"`
+	ErrorWhenStderr []string `json:"errorWhenStderr"` // Like errorWhen, failing as soon as a stderr line matches.
+}
+
+// Discard redirects an output stream to nowhere.
+const Discard = "discard"
+
+// Output configures how the output of a task is printed.
+type Output struct {
+	JSON      bool     `json:"json"`      // Parse JSON lines, printing fields rather than the object.
+	Fields    []string `json:"fields"`    // Fields of JSON lines printed in order, all if empty.
+	Level     string   `json:"level"`     // Field of JSON lines colored by, 'level' by default.
+	Filter    []string `json:"filter"`    // Lines matching any pattern are not printed.
+	Highlight []string `json:"highlight"` // Lines matching any pattern are printed in bold.
+}
+
+// When is a condition for including a task, evaluated when the Taskfile is read. Every
+// set field must hold, a '!' prefix negates an entry.
+type When struct {
+	If     *bool    `json:"if"`     // Evaluated by CUE, e.g. 'builtin.os == "linux"'.
+	Exists []string `json:"exists"` // Paths or glob patterns.
+	Env    []string `json:"env"`    // 'NAME' is set and not empty, or 'NAME=value'.
+	Flags  []string `json:"flags"`  // Flags that are set, by name, e.g. 'optionals'.
+	Pulled []string `json:"pulled"` // Artifacts, by ID or URL, that have been pulled.
+}
+
+// Builtin is a plugin callable as 'gpm:<name>'. The command receives the flags and
+// artifacts as JSON on stdin, and may report a result as JSON on stdout.
+type Builtin struct {
+	Name string   `json:"name"`
+	Desc string   `json:"desc"`
+	Cmd  []string `json:"cmd"`
+	Dir  string   `json:"dir"`
 }
 
 type Download struct {
+	ID        string   `json:"id"`
 	URL       string   `json:"url"`
-	Sha       string   `json:"sha"`
+	Mirrors   []string `json:"mirrors"` // Tried in order if URL fails.
+	Sha       string   `json:"sha"`     // A sha256, or a digest prefixed by its algorithm, e.g. 'sha512:'.
 	Dir       string   `json:"dir"`
 	Filename  string   `json:"filename"`
 	Extract   string   `json:"extract"`
 	Platforms []string `json:"platform"`
 	Optional  bool     `json:"optional"`
 	Force     bool     `json:"force"`
+	// A checksum file, used if Sha is empty, and a detached ed25519 signature.
+	ShaURL     string   `json:"shaUrl"`
+	Signature  string   `json:"signature"`
+	PublicKeys []string `json:"publicKeys"`
+	// Post-extract actions, paths are relative to the extraction directory.
+	Strip         int       `json:"strip"`
+	Move          []Move    `json:"move"`
+	Chmod         []string  `json:"chmod"`
+	Symlink       []Symlink `json:"symlink"`
+	DeleteArchive bool      `json:"deleteArchive"`
+}
+
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Symlink struct {
+	Target string `json:"target"`
+	Link   string `json:"link"`
 }
 
 type File struct {
-	Name string `json:"name"`
-	Sha  string `json:"sha"`
+	Name    string   `json:"name"` // Path or glob pattern.
+	Sha     string   `json:"sha"`
+	Exclude []string `json:"exclude"`
+	Keep    int      `json:"keep"`   // Keep the N most recently modified matches.
+	MaxAge  string   `json:"maxAge"` // Only remove matches older than this duration.
 }
 
 type Artifacts struct {
@@ -54,6 +126,15 @@ type Artifacts struct {
 	Prune []File     `json:"prune"`
 }
 
+// Key returns the ID of the download, falling back to its URL.
+func (d Download) Key() string {
+	if d.ID != "" {
+		return d.ID
+	}
+
+	return d.URL
+}
+
 // Merge merges the receiver with the target.
 func (t Taskfile) Merge(target Taskfile) Taskfile {
 	return Taskfile{
@@ -72,7 +153,7 @@ func (t Taskfile) Merge(target Taskfile) Taskfile {
 				t.Artifacts.Pull,
 				target.Artifacts.Pull,
 				func(d Download) string {
-					return d.URL
+					return d.Key()
 				},
 			),
 			Prune: maputil.AppendNewByKey(
@@ -83,5 +164,11 @@ func (t Taskfile) Merge(target Taskfile) Taskfile {
 				},
 			),
 		},
+		Builtins: maputil.AppendOverwriteByKey(t.Builtins, target.Builtins, func(b Builtin) string {
+			return b.Name
+		}),
+		Groups: maputil.AppendOverwriteByKey(t.Groups, target.Groups, func(g Group) string {
+			return g.Name
+		}),
 	}
 }
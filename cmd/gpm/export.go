@@ -48,7 +48,14 @@ func exportUpstart(path string) error {
 			return errutil.New("os.ReadFile", err)
 		}
 
-		fmt.Fprintf(f, "env PORT=%d\n", ctx.Flags.BasePort+uint(i))
+		// Without -set-ports, PORT is still assigned by position.
+		if len(proc.Ports) == 0 {
+			fmt.Fprintf(f, "env PORT=%d\n", ctx.Flags.BasePort+uint(i))
+		}
+
+		for _, port := range proc.PortEnv() {
+			fmt.Fprintf(f, "env %s\n", port)
+		}
 
 		for k, v := range env {
 			fmt.Fprintf(f, "env %s='%s'\n", k, strings.ReplaceAll(v, "'", "\\'"))
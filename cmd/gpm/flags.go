@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 
 	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
 	"github.com/ricochhet/pkg/flagutil"
+	"github.com/ricochhet/pkg/logutil"
 )
 
+var ErrLogFormat = errors.New("unknown log format")
+
 var Flag = NewFlags()
 
 // NewFlags creates an empty Flags.
@@ -60,13 +65,43 @@ func registerFlags(fs *flag.FlagSet, f *config.Flags) {
 	)
 	fs.BoolVar(&f.ExitOnStop, "exit-on-stop", true, "Exit gpm if all subprocesses stop")
 	fs.BoolVar(&f.LogTime, "logtime", true, "show timestamp in log")
+	fs.BoolVar(&f.NoColor, "no-color", false, "print without colors (also set by NO_COLOR)")
+	fs.StringVar(&f.LogFormat, "log-format", "text", "format of task output and events (text, json)")
 	fs.BoolVar(&f.Pty, "pty", false, "Use a PTY for all subprocesses (noop on Windows)")
 	fs.UintVar(&f.Interval, "interval", 0, "the interval at which to start applications")
 	fs.BoolVar(&f.ReverseOnStop, "reverse-on-stop", false, "reverse procs sort when stop")
 	fs.BoolVar(&f.InheritStdin, "inherit-stdin", false, "inherit stdin from gpm")
 	fs.IntVar(&f.VarPasses, "var-passes", 3, "maximum passes variables will do while parsing")
-	fs.StringVar(&f.Global, "g", flagutil.Set("", dotfileFlag), "use global dotfile or taskfile")
+	fs.StringVar(&f.Global, "g", flagutil.Set("", dotfileFlag),
+		"read from next to the executable instead of the working directory ("+
+			dotfileFlag.Format()+", "+taskfileFlag.Format()+")")
 	fs.BoolVar(&f.Debug, "debug", false, "enable debug mode")
 	fs.BoolVar(&f.QuickEdit, "quick-edit", false, "enable quick edit mode")
-	fs.BoolVar(&f.Optionals, "optionals", false, "download optional artifacts")
+	fs.BoolVar(&f.Optionals, "optionals", false, "also download artifacts marked optional (pull)")
+	fs.BoolVar(&f.JSON, "json", false, "use JSON output where supported (check)")
+	fs.BoolVar(&f.Offline, "offline", false, "only use cached remote includes")
+	fs.BoolVar(&f.Update, "update", false, "refresh the lockfile instead of verifying against it")
+	fs.IntVar(&f.Jobs, "jobs", 4, "maximum number of concurrent artifact downloads")
+	fs.StringVar(&f.CacheDir, "cache-dir", "", "download cache directory (default: user cache dir)")
+	fs.BoolVar(&f.DryRun, "dry-run", false, "list files that would be pruned, without removing them")
+	fs.StringVar(&f.PluginsDir, "plugins-dir", "", "plugin directory searched before PATH (default: user config dir)")
+	fs.BoolVar(&f.Verbose, "v", false, "verbose output where supported (check)")
+	fs.StringVar(&f.Token, "token", "", "cleartext token authenticating RPC connections (also set by GPM_RPC_TOKEN)")
+	fs.StringVar(&f.Controller, "controller", "", "register as an agent with the controller at host:port, requires -token")
+	fs.StringVar(&f.AgentName, "agent-name", "", "name of this agent (default: hostname)")
+	fs.StringVar(&f.AgentAddr, "agent-addr", "", "RPC address the controller reaches this agent at (default: hostname:port)")
+}
+
+// setLogFormat sets the format logs are printed in.
+func setLogFormat(format string) error {
+	switch format {
+	case "", "text":
+		logutil.LogJSON.Store(false)
+	case "json":
+		logutil.LogJSON.Store(true)
+	default:
+		return errutil.WithFramef("%w: %s", ErrLogFormat, format)
+	}
+
+	return nil
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/cryptoutil"
+	"github.com/ricochhet/pkg/dlutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+var (
+	ErrInsecureInclude  = errors.New("remote includes must use https")
+	ErrIncludeNotCached = errors.New("remote include is not cached")
+	ErrGitInclude       = errors.New("git includes are not supported, use the https URL of the raw Taskfile")
+)
+
+// isRemoteInclude returns true if the include refers to a remote Taskfile.
+func isRemoteInclude(include string) bool {
+	return strings.HasPrefix(include, "https://") || strings.HasPrefix(include, "http://") ||
+		isGitInclude(include)
+}
+
+// isGitInclude returns true if the include refers to a git repository, e.g.
+// 'git@host:repo.git' or 'ssh://host/repo'. Only single files served over https can
+// be included.
+func isGitInclude(include string) bool {
+	for _, prefix := range []string{"git://", "git+", "ssh://", "git@"} {
+		if strings.HasPrefix(include, prefix) {
+			return true
+		}
+	}
+
+	return strings.HasPrefix(include, "https://") && strings.HasSuffix(include, ".git")
+}
+
+// isIncludesUpdate returns true if gpm was started with 'includes update'.
+func isIncludesUpdate() bool {
+	return len(ctx.Flags.Args) >= 2 &&
+		ctx.Flags.Args[0] == "includes" &&
+		ctx.Flags.Args[1] == "update"
+}
+
+// resolveInclude returns the local path of the include. Remote includes are
+// downloaded into the cache and verified against the sha pinned in the lockfile.
+// If update is true, the include is downloaded again and re-pinned.
+func resolveInclude(include string, lock *config.Lockfile, update bool) (string, error) {
+	if !isRemoteInclude(include) {
+		return maybeGlobalTaskfile(include)
+	}
+
+	if isGitInclude(include) {
+		return "", errutil.WithFramef("%w: %s", ErrGitInclude, include)
+	}
+
+	if !strings.HasPrefix(include, "https://") {
+		return "", errutil.WithFramef("%w: %s", ErrInsecureInclude, include)
+	}
+
+	cache, err := config.CacheDir(ctx.Flags.CacheDir)
+	if err != nil {
+		return "", errutil.WithFrame(err)
+	}
+
+	filename, err := fsutil.URLFilename(include)
+	if err != nil {
+		return "", errutil.New("fsutil.URLFilename", err)
+	}
+
+	client, err := ctx.Flags.Download.Client()
+	if err != nil {
+		return "", errutil.WithFrame(err)
+	}
+
+	// Key the cached file by the URL so different includes sharing a filename do not collide.
+	key := sha256.Sum256([]byte(include))
+	d := dlutil.Download{
+		URL:       include,
+		Directory: filepath.Join(cache, "includes", hex.EncodeToString(key[:8])),
+		Filename:  filename,
+		Client:    client,
+	}
+	path := filepath.Join(d.Directory, d.Filename)
+
+	pinned, ok := lock.Include(include)
+	if ok && !update {
+		d.SHA256 = pinned.Sha
+	}
+
+	if ctx.Flags.Offline {
+		if err := d.NewDefaultValidator(path); err != nil {
+			return "", errutil.WithFramef("%w: %s", ErrIncludeNotCached, include)
+		}
+
+		return path, nil
+	}
+
+	validator := d.NewDefaultValidator
+	if update {
+		validator = nil
+	}
+
+	if err := d.Download(context.TODO(), dlutil.NewDefaultMessenger(), validator); err != nil {
+		return "", errutil.New("Download", err)
+	}
+
+	if ok && !update {
+		return path, nil
+	}
+
+	sum, err := cryptoutil.NewSHA256(path)
+	if err != nil {
+		return "", errutil.New("cryptoutil.NewSHA256", err)
+	}
+
+	lock.SetInclude(config.LockedInclude{URL: include, Sha: sum})
+	logutil.Infof(os.Stdout, "Pinned %s (%s)\n", include, sum)
+
+	return path, nil
+}
+
+// command: includes.
+func includes(cmd string) error {
+	if cmd != "update" {
+		return errutil.WithFramef("%w: includes %s", ErrUnknownCommand, cmd)
+	}
+
+	// Remote includes are re-pinned while reading the Taskfile, see isIncludesUpdate.
+	lock, err := config.ReadLockfile(config.LockfilePath(taskfilePaths[0]))
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	for _, li := range lock.Includes {
+		logutil.Infof(os.Stdout, "%s %s\n", li.Sha, li.URL)
+	}
+
+	return nil
+}
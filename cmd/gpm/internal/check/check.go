@@ -0,0 +1,461 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/cueutil"
+	"github.com/ricochhet/pkg/dlutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/maputil"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Diagnostic struct {
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+type Report struct {
+	Tasks       []string            `json:"tasks"`
+	Groups      map[string][]string `json:"groups,omitempty"` // Expanded tasks, by group.
+	Skipped     []Skipped           `json:"skipped,omitempty"`
+	Diagnostics []Diagnostic        `json:"diagnostics"`
+}
+
+type Linter struct {
+	Taskfile config.Taskfile
+	Paths    []string
+	Builtins []string
+	Compile  map[string]any
+	// PublicKeys are the globally configured artifact signature keys.
+	PublicKeys []string
+	// Skipped are the tasks excluded by their platform or when clause.
+	Skipped []Skipped
+}
+
+type Skipped struct {
+	Task   string `json:"task"`
+	Reason string `json:"reason"`
+}
+
+// Run runs every check against the receiver, returning a Report.
+func (l *Linter) Run() (*Report, error) {
+	report := &Report{Diagnostics: []Diagnostic{}}
+
+	diags, err := l.Schema()
+	if err != nil {
+		return nil, errutil.WithFrame(err)
+	}
+
+	report.Diagnostics = slices.Concat(
+		diags,
+		l.References(),
+		l.Aliases(),
+		l.Commands(),
+		l.Artifacts(),
+		l.Readiness(),
+		l.When(),
+		l.Output(),
+	)
+
+	groups, diags := l.Groups()
+	report.Groups = groups
+	report.Skipped = l.Skipped
+	report.Diagnostics = append(report.Diagnostics, diags...)
+
+	for _, task := range l.Taskfile.Tasks {
+		report.Tasks = append(report.Tasks, strings.TrimSpace(task.Name))
+	}
+
+	slices.Sort(report.Tasks)
+
+	return report, nil
+}
+
+// Schema validates every Taskfile path against config.Schema.
+func (l *Linter) Schema() ([]Diagnostic, error) {
+	schema := cueutil.NewSchema(config.Schema, config.SchemaDefinition)
+	schema.Compile(l.Compile)
+
+	diags := []Diagnostic{}
+
+	for _, path := range l.Paths {
+		errs, err := schema.File(path)
+		if err != nil {
+			return nil, errutil.New("schema.File", err)
+		}
+
+		for _, e := range errs {
+			msg := e.Message
+			if e.Path != "" {
+				msg = e.Path + ": " + msg
+			}
+
+			diags = append(diags, Diagnostic{
+				File:     e.Filename,
+				Line:     e.Line,
+				Column:   e.Column,
+				Severity: SeverityError,
+				Code:     "schema",
+				Message:  msg,
+			})
+		}
+	}
+
+	return diags, nil
+}
+
+// References reports steps and runas tasks that do not refer to a known task, builtin
+// or group.
+func (l *Linter) References() []Diagnostic {
+	known := slices.Clone(l.Builtins)
+
+	for _, task := range l.Taskfile.Tasks {
+		known = append(known, strings.TrimSpace(task.Name))
+		known = append(known, task.Aliases...)
+	}
+
+	diags := []Diagnostic{}
+
+	for _, task := range l.Taskfile.Tasks {
+		for _, step := range task.Steps {
+			if !l.refers(known, step) {
+				diags = append(diags, newDiagnostic(SeverityError, "reference",
+					"task %q: step %q does not refer to a task or builtin", task.Name, step))
+			}
+		}
+	}
+
+	for _, run := range l.Taskfile.Runas {
+		// Without start, runas tasks are gpm commands rather than task names.
+		if !run.Start {
+			continue
+		}
+
+		for _, name := range run.Tasks {
+			if !l.refers(known, name) {
+				diags = append(diags, newDiagnostic(SeverityError, "reference",
+					"runas %q: task %q does not refer to a task or builtin", run.Name, name))
+			}
+		}
+	}
+
+	return diags
+}
+
+// refers returns true if the name is known, or is a group that can be expanded. The
+// tasks of groups are reported by Groups.
+func (l *Linter) refers(known []string, name string) bool {
+	if config.IsGroup(name) {
+		_, _, err := l.Taskfile.Groups.Expand([]string{name})
+		return err == nil
+	}
+
+	return slices.Contains(known, name)
+}
+
+// Aliases reports aliases that are used by more than one task, or shadow a task name.
+func (l *Linter) Aliases() []Diagnostic {
+	owners := map[string]string{}
+
+	for _, task := range l.Taskfile.Tasks {
+		owners[strings.TrimSpace(task.Name)] = task.Name
+	}
+
+	diags := []Diagnostic{}
+
+	for _, task := range l.Taskfile.Tasks {
+		for _, alias := range task.Aliases {
+			if owner, ok := owners[alias]; ok && owner != task.Name {
+				diags = append(diags, newDiagnostic(SeverityError, "alias",
+					"task %q: alias %q is already used by task %q", task.Name, alias, owner))
+
+				continue
+			}
+
+			owners[alias] = task.Name
+		}
+	}
+
+	return diags
+}
+
+// Commands reports tasks whose executable does not exist on PATH or at the given path.
+// Tasks that do not run on the current platform are skipped.
+func (l *Linter) Commands() []Diagnostic {
+	diags := []Diagnostic{}
+
+	for _, task := range l.Taskfile.Tasks {
+		if len(task.Cmd) == 0 || slices.Contains(l.Builtins, task.Cmd[0]) {
+			continue
+		}
+
+		if len(task.Platforms) != 0 && !slices.Contains(task.Platforms, runtime.GOOS) {
+			continue
+		}
+
+		if !l.lookPath(task.Dir, task.Cmd[0]) {
+			diags = append(diags, newDiagnostic(SeverityWarning, "command",
+				"task %q: executable %q was not found", task.Name, task.Cmd[0]))
+		}
+	}
+
+	return diags
+}
+
+// Groups expands every group, reporting groups that include an unknown group or
+// themselves, and tasks that do not refer to a task or builtin.
+func (l *Linter) Groups() (map[string][]string, []Diagnostic) {
+	known := slices.Clone(l.Builtins)
+
+	for _, task := range l.Taskfile.Tasks {
+		known = append(known, strings.TrimSpace(task.Name))
+		known = append(known, task.Aliases...)
+	}
+
+	groups := map[string][]string{}
+	diags := []Diagnostic{}
+
+	for _, group := range l.Taskfile.Groups {
+		tasks, _, err := l.Taskfile.Groups.Expand([]string{config.GroupPrefix + group.Name})
+		if err != nil {
+			diags = append(diags, newDiagnostic(SeverityError, "group",
+				"group %q: %v", group.Name, errors.Unwrap(err)))
+
+			continue
+		}
+
+		for _, task := range tasks {
+			if !slices.Contains(known, task) {
+				diags = append(diags, newDiagnostic(SeverityError, "reference",
+					"group %q: task %q does not refer to a task or builtin", group.Name, task))
+			}
+		}
+
+		if group.SetPorts || group.BasePort != 0 {
+			diags = append(diags, newDiagnostic(SeverityWarning, "group",
+				"group %q: setPorts and baseport have no effect, ports are assigned before groups are started",
+				group.Name))
+		}
+
+		groups[group.Name] = tasks
+	}
+
+	return groups, diags
+}
+
+// Readiness reports invalid readiness patterns and timeouts, waitFor entries that do
+// not refer to a task, and tasks that wait for each other.
+func (l *Linter) Readiness() []Diagnostic {
+	diags := []Diagnostic{}
+	waits := map[string][]string{}
+
+	for _, task := range l.Taskfile.Tasks {
+		for _, pattern := range slices.Concat(task.ReadyWhen, task.ErrorWhen, task.ErrorWhenStderr) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				diags = append(diags, newDiagnostic(SeverityError, "readiness",
+					"task %q: %v", task.Name, err))
+			}
+		}
+
+		if task.ReadyTimeout != "" {
+			if _, err := time.ParseDuration(task.ReadyTimeout); err != nil {
+				diags = append(diags, newDiagnostic(SeverityError, "readiness",
+					"task %q: %v", task.Name, err))
+			} else if len(task.ReadyWhen) == 0 {
+				diags = append(diags, newDiagnostic(SeverityWarning, "readiness",
+					"task %q has a readyTimeout, but no readyWhen patterns", task.Name))
+			}
+		}
+
+		waits[strings.TrimSpace(task.Name)] = task.WaitFor
+		for _, alias := range task.Aliases {
+			waits[alias] = task.WaitFor
+		}
+	}
+
+	for _, task := range l.Taskfile.Tasks {
+		for _, name := range task.WaitFor {
+			if _, ok := waits[name]; !ok {
+				diags = append(diags, newDiagnostic(SeverityError, "reference",
+					"task %q: waitFor %q does not refer to a task", task.Name, name))
+			}
+		}
+
+		if waitsFor(waits, strings.TrimSpace(task.Name), task.WaitFor, map[string]bool{}) {
+			diags = append(diags, newDiagnostic(SeverityError, "readiness",
+				"task %q waits for itself", task.Name))
+		}
+	}
+
+	return diags
+}
+
+// waitsFor returns true if any of the names waits for target, directly or indirectly.
+func waitsFor(waits map[string][]string, target string, names []string, seen map[string]bool) bool {
+	for _, name := range names {
+		if name == target {
+			return true
+		}
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		if waitsFor(waits, target, waits[name], seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// When reports when clauses that refer to an unknown flag or artifact.
+func (l *Linter) When() []Diagnostic {
+	flags := maputil.StructToMap(config.Flags{}, "json", true)
+	diags := []Diagnostic{}
+
+	for _, task := range l.Taskfile.Tasks {
+		for _, name := range task.When.Flags {
+			if _, ok := flags[strings.TrimPrefix(name, "!")]; !ok {
+				diags = append(diags, newDiagnostic(SeverityError, "when",
+					"task %q: flag %q does not exist", task.Name, name))
+			}
+		}
+
+		for _, key := range task.When.Pulled {
+			key = strings.TrimPrefix(key, "!")
+			if !slices.ContainsFunc(l.Taskfile.Artifacts.Pull, func(dl config.Download) bool {
+				return dl.Key() == key
+			}) {
+				diags = append(diags, newDiagnostic(SeverityError, "when",
+					"task %q: artifact %q does not refer to an artifact id or url", task.Name, key))
+			}
+		}
+	}
+
+	return diags
+}
+
+// Output reports invalid output filter and highlight patterns, and redirections that
+// are ignored.
+func (l *Linter) Output() []Diagnostic {
+	diags := []Diagnostic{}
+
+	for _, task := range l.Taskfile.Tasks {
+		for _, pattern := range slices.Concat(task.Output.Filter, task.Output.Highlight) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				diags = append(diags, newDiagnostic(SeverityError, "output",
+					"task %q: %v", task.Name, err))
+			}
+		}
+
+		redirected := task.Stdout != "" || task.Stderr != ""
+		if redirected && (task.Fork || task.Pty) {
+			diags = append(diags, newDiagnostic(SeverityWarning, "output",
+				"task %q redirects its output, which is ignored with fork or pty", task.Name))
+		}
+	}
+
+	return diags
+}
+
+// Artifacts reports artifacts that are not pinned by a sha, or have an invalid sha.
+func (l *Linter) Artifacts() []Diagnostic {
+	diags := []Diagnostic{}
+
+	for _, dl := range l.Taskfile.Artifacts.Pull {
+		if dl.Sha == "" && dl.ShaURL == "" {
+			diags = append(diags, newDiagnostic(SeverityWarning, "artifact",
+				"artifact %q has no sha", dl.URL))
+		}
+
+		if _, err := dlutil.ParseDigest(dl.Sha); err != nil {
+			diags = append(diags, newDiagnostic(SeverityError, "artifact",
+				"artifact %q: %v", dl.URL, err))
+		}
+
+		if dl.Signature != "" && len(dl.PublicKeys) == 0 && len(l.PublicKeys) == 0 {
+			diags = append(diags, newDiagnostic(SeverityError, "artifact",
+				"artifact %q has a signature, but no public keys are configured", dl.URL))
+		}
+	}
+
+	return diags
+}
+
+// HasErrors returns true if any diagnostic in the receiver is an error.
+func (r *Report) HasErrors() bool {
+	return slices.ContainsFunc(r.Diagnostics, func(d Diagnostic) bool {
+		return d.Severity == SeverityError
+	})
+}
+
+// String returns a string representation of the receiver.
+func (d Diagnostic) String() string {
+	switch {
+	case d.File != "" && d.Line != 0:
+		return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Code, d.Message)
+	case d.File != "":
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Code, d.Message)
+	default:
+		return fmt.Sprintf("%s: %s", d.Code, d.Message)
+	}
+}
+
+// lookPath checks if the executable exists, either at the path relative to dir,
+// on PATH, or in any PATH entry declared by the Taskfile.
+func (l *Linter) lookPath(dir, name string) bool {
+	if strings.ContainsAny(name, `/\`) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+
+		_, err := exec.LookPath(name)
+
+		return err == nil || fsutil.Exists(name)
+	}
+
+	if _, err := exec.LookPath(name); err == nil {
+		return true
+	}
+
+	for _, path := range l.Taskfile.Env["PATH"] {
+		if _, err := exec.LookPath(filepath.Join(path, name)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newDiagnostic returns a Diagnostic without a position.
+func newDiagnostic(severity Severity, code, format string, a ...any) Diagnostic {
+	return Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
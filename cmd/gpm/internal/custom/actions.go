@@ -0,0 +1,255 @@
+package custom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+var (
+	ErrStripCollision = errors.New("stripped path already exists")
+	ErrOutsideDir     = errors.New("path is outside the extraction directory")
+)
+
+var archiveExts = []string{".7z", ".rar", ".zip", ".tar", ".gz"}
+
+type artifactPaths struct {
+	Filename string
+	Tarball  string
+	// Dir is the extraction directory for archives, and the download directory otherwise.
+	Dir     string
+	Archive bool
+}
+
+// newArtifactPaths resolves the paths an artifact is downloaded and extracted to.
+func newArtifactPaths(dl config.Download) (*artifactPaths, error) {
+	filename, err := fsutil.URLFilename(dl.URL)
+	if err != nil {
+		return nil, errutil.New("fsutil.URLFilename", err)
+	}
+
+	if dl.Filename != "" {
+		filename = dl.Filename
+	}
+
+	p := &artifactPaths{
+		Filename: filename,
+		Tarball:  filepath.Join(dl.Dir, filename),
+		Dir:      dl.Dir,
+		Archive:  slices.Contains(archiveExts, filepath.Ext(filename)) || dl.Force,
+	}
+
+	if p.Archive {
+		extract := dl.Dir
+		if dl.Extract != "" {
+			extract = dl.Extract
+		}
+
+		p.Dir = filepath.Join(extract, fsutil.Filename(filename))
+	}
+
+	return p, nil
+}
+
+// postExtract runs the post-extract actions of the artifact. Every action is
+// idempotent, so they are run on every pull, not only after extraction.
+func postExtract(logger *logutil.Logger, dl config.Download) error {
+	p, err := newArtifactPaths(dl)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	if !fsutil.Exists(p.Dir) {
+		return nil
+	}
+
+	for _, mv := range dl.Move {
+		from, to, err := p.join2(mv.From, mv.To)
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		if err := move(logger, from, to); err != nil {
+			return errutil.New("move", err)
+		}
+	}
+
+	for _, name := range dl.Chmod {
+		path, err := p.join(name)
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		if err := chmodExecutable(path); err != nil {
+			return errutil.New("chmodExecutable", err)
+		}
+	}
+
+	for _, ln := range dl.Symlink {
+		target, link, err := p.join2(ln.Target, ln.Link)
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		if err := symlink(logger, target, link); err != nil {
+			return errutil.New("symlink", err)
+		}
+	}
+
+	if dl.DeleteArchive && p.Archive && fsutil.Exists(p.Tarball) {
+		logger.Eventf(logutil.LevelInfo, "remove", logutil.Fields{"path": p.Tarball}, "Removing: %s\n", p.Tarball)
+
+		if err := os.Remove(p.Tarball); err != nil {
+			return errutil.New("os.Remove", err)
+		}
+	}
+
+	return nil
+}
+
+// join returns the path of the name within the extraction directory. Names that
+// are absolute, or refer outside of the directory with '..', are rejected, so a
+// Taskfile cannot modify files outside of it.
+func (p *artifactPaths) join(name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", errutil.WithFramef("%w: %s", ErrOutsideDir, name)
+	}
+
+	return filepath.Join(p.Dir, name), nil
+}
+
+// join2 is like join, for a pair of names.
+func (p *artifactPaths) join2(a, b string) (string, string, error) {
+	pa, err := p.join(a)
+	if err != nil {
+		return "", "", err
+	}
+
+	pb, err := p.join(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	return pa, pb, nil
+}
+
+// stripComponents moves every entry n directories below src into dst, then removes src.
+// Files fewer than n directories deep are discarded, matching tar --strip-components.
+func stripComponents(src, dst string, n int) error {
+	dirs := []string{src}
+
+	for range n {
+		var next []string
+
+		for _, dir := range dirs {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return errutil.New("os.ReadDir", err)
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() {
+					next = append(next, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+
+		dirs = next
+	}
+
+	// Stage into a sibling of dst, so an interrupted strip is never treated as extracted.
+	staged := dst + ".strip"
+	if err := fsutil.RemoveAll(staged); err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	if err := os.MkdirAll(staged, 0o755); err != nil {
+		return errutil.New("os.MkdirAll", err)
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return errutil.New("os.ReadDir", err)
+		}
+
+		for _, entry := range entries {
+			path := filepath.Join(staged, entry.Name())
+			if fsutil.Exists(path) {
+				return errutil.WithFramef("%w: %s", ErrStripCollision, entry.Name())
+			}
+
+			if err := os.Rename(filepath.Join(dir, entry.Name()), path); err != nil {
+				return errutil.New("os.Rename", err)
+			}
+		}
+	}
+
+	if err := os.Rename(staged, dst); err != nil {
+		return errutil.New("os.Rename", err)
+	}
+
+	return fsutil.RemoveAll(src)
+}
+
+// move moves from to to, unless it has already been moved.
+func move(logger *logutil.Logger, from, to string) error {
+	if !fsutil.Exists(from) && fsutil.Exists(to) {
+		return nil
+	}
+
+	logger.Eventf(logutil.LevelInfo, "move", logutil.Fields{"from": from, "to": to}, "Moving %s to %s\n", from, to)
+
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return errutil.New("os.MkdirAll", err)
+	}
+
+	return os.Rename(from, to)
+}
+
+// chmodExecutable adds the executable bits to the file mode of path.
+func chmodExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errutil.New("os.Stat", err)
+	}
+
+	if info.Mode()&0o111 == 0o111 {
+		return nil
+	}
+
+	return os.Chmod(path, info.Mode()|0o111)
+}
+
+// symlink creates a link to target, relative to the link, replacing any other link at its path.
+func symlink(logger *logutil.Logger, target, link string) error {
+	rel, err := filepath.Rel(filepath.Dir(link), target)
+	if err != nil {
+		return errutil.New("filepath.Rel", err)
+	}
+
+	if current, err := os.Readlink(link); err == nil {
+		if current == rel {
+			return nil
+		}
+
+		if err := os.Remove(link); err != nil {
+			return errutil.New("os.Remove", err)
+		}
+	}
+
+	logger.Eventf(logutil.LevelInfo, "symlink", logutil.Fields{"link": link, "target": rel},
+		"Linking %s to %s\n", link, rel)
+
+	if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+		return errutil.New("os.MkdirAll", err)
+	}
+
+	return os.Symlink(rel, link)
+}
@@ -2,10 +2,15 @@ package custom
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
+	"net/http"
+	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strings"
+	"sync"
 
 	"github.com/avast/retry-go"
 	"github.com/ricochhet/gpm/config"
@@ -29,20 +34,13 @@ var (
 	ErrNotArchive       = errors.New("not an archive")
 	ErrAlreadyExtracted = errors.New("already extracted")
 	ErrNeverExtracted   = errors.New("never extracted")
+	ErrLockDrift        = errors.New("artifact does not match lockfile")
 )
 
 type archiveJob struct {
-	Tarball string
-	Dir     string
-}
-
-// messenger returns the download messenger to use.
-func messenger(logger *logutil.Logger) *dlutil.Messenger {
-	return &dlutil.Messenger{
-		Start: func(f string) {
-			logutil.Infof(logger, "Downloading: %s\n", f)
-		},
-	}
+	Tarball  string
+	Dir      string
+	Download config.Download
 }
 
 // retryOpts returns the []retry.Option to use.
@@ -51,20 +49,57 @@ func retryOpts(logger *logutil.Logger) *[]retry.Option {
 		retry.Attempts(5),
 		retry.LastErrorOnly(true),
 		retry.OnRetry(func(n uint, err error) {
-			logutil.Infof(logger, "Retry %d: %v\n", n, err)
+			logger.Eventf(logutil.LevelInfo, "retry", logutil.Fields{"attempt": n, "error": err.Error()},
+				"Retry %d: %v\n", n, err)
 		}),
 	}
 }
 
 // Download downloads the specified data, and extracts based on the receiver value.
+// Every artifact is verified against, and recorded to, the lockfile.
 func (m ExtractionMode) Download(
 	logger *logutil.Logger,
 	downloads []config.Download,
+	lock *config.Lockfile,
 	flags *config.Flags,
 ) error {
 	dlutil.RetryOpts(*retryOpts(logger))
 
-	var jobs []archiveJob
+	dir, err := config.CacheDir(flags.CacheDir)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	cache := NewCache(dir)
+
+	client, err := flags.Download.Client()
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	progress := newProgress(logger)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		jobs []archiveJob
+		errs []error
+	)
+
+	// fail records the error and cancels any pending or in-flight downloads.
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs = append(errs, err)
+
+		cancel()
+	}
+
+	sem := make(chan struct{}, max(1, flags.Jobs))
 
 	for _, dl := range downloads {
 		if len(dl.Platforms) != 0 && !slices.Contains(dl.Platforms, runtime.GOOS) {
@@ -75,128 +110,301 @@ func (m ExtractionMode) Download(
 			continue
 		}
 
-		job, err := m.download(logger, *messenger(logger), dl)
+		wg.Add(1)
 
-		switch {
-		case errors.Is(err, ErrNotArchive),
-			errors.Is(err, ErrAlreadyExtracted),
-			errors.Is(err, ErrNeverExtracted):
-		case err != nil:
-			logutil.Infof(logger, "Failed to download %s: %v\n", dl.URL, err)
-			return errutil.New("download", err)
-		}
+		go func() {
+			defer wg.Done()
 
-		if job != nil {
-			jobs = append(jobs, *job)
-		}
-	}
+			sem <- struct{}{}
 
-	if m == ExtractAfterAll {
-		for _, job := range jobs {
-			if err := unarchive(logger, job.Tarball, job.Dir); err != nil {
-				return errutil.New("unarchive", err)
+			if ctx.Err() != nil {
+				<-sem
+				return
 			}
-		}
-	}
 
-	return nil
-}
+			job, err := m.download(ctx, logger, progress.messenger(dl.Key(), dl.Dir), client, cache, dl, lock, flags)
 
-// Remove attempts to remove all files within the files slice.
-// If a SHA256 is specified, it will only remove if the hash matches.
-func Remove(logger *logutil.Logger, files []config.File) error {
-	for _, dl := range files {
-		path, err := fsutil.FromCwd(dl.Name)
-		if err != nil {
-			return errutil.New("fsutil.FromCwd", err)
-		}
+			// Release before extracting, so extraction overlaps with other downloads.
+			<-sem
 
-		if !fsutil.Exists(path) {
-			continue
-		}
+			switch {
+			case errors.Is(err, ErrNotArchive),
+				errors.Is(err, ErrAlreadyExtracted):
+				if err := postExtract(logger, dl); err != nil {
+					fail(errutil.New("postExtract", err))
+				}
+
+				return
+			case errors.Is(err, ErrNeverExtracted):
+			case err != nil:
+				if ctx.Err() == nil {
+					logger.Eventf(logutil.LevelInfo, "download", logutil.Fields{"url": dl.URL, "error": err.Error()},
+						"Failed to download %s: %v\n", dl.URL, err)
+				}
 
-		if dl.Sha != "" {
-			sum, err := cryptoutil.NewSHA256(path)
-			if err != nil {
-				return errutil.New("cryptoutil.NewSHA256", err)
+				fail(errutil.New("download", err))
+
+				return
 			}
 
-			if dl.Sha != sum {
-				continue
+			if job == nil {
+				return
 			}
-		}
 
-		logutil.Infof(logger, "Removing: %s\n", path)
+			if m == ExtractImmediately {
+				if err := job.extract(logger); err != nil {
+					fail(errutil.WithFrame(err))
+				}
 
-		if err := fsutil.RemoveAll(path); err != nil {
-			logutil.Infof(logger, "Failed to remove file: %s\n", path)
-			continue
+				return
+			}
+
+			mu.Lock()
+			jobs = append(jobs, *job)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	progress.Stop()
+
+	if len(errs) != 0 {
+		return errs[0]
+	}
+
+	if m == ExtractAfterAll {
+		for _, job := range jobs {
+			if err := job.extract(logger); err != nil {
+				return errutil.WithFrame(err)
+			}
 		}
 	}
 
 	return nil
 }
 
-// download downloads the file and assigns an extraction job.
+// download downloads the file, or links it from the cache, and assigns an extraction job.
+// Mirrors are tried in order if a URL fails, each verified against the same sha. If
+// flags.Update is false, the file must match the lockfile entry for its key, if one exists.
 func (m ExtractionMode) download(
+	ctx context.Context,
 	logger *logutil.Logger,
 	messenger dlutil.Messenger,
+	client *http.Client,
+	cache *Cache,
 	dl config.Download,
+	lock *config.Lockfile,
+	flags *config.Flags,
 ) (*archiveJob, error) {
-	arcs := []string{".7z", ".rar", ".zip", ".tar", ".gz"}
+	update := flags.Update
+
+	p, err := newArtifactPaths(dl)
+	if err != nil {
+		return nil, errutil.WithFrame(err)
+	}
+
+	// The archive was deleted after a previous extraction, there is nothing to verify.
+	if dl.DeleteArchive && p.Archive && fsutil.Exists(p.Dir) && !fsutil.Exists(p.Tarball) {
+		return nil, ErrAlreadyExtracted
+	}
+
+	filename, tarball := p.Filename, p.Tarball
 
-	filename, err := fsutil.URLFilename(dl.URL)
+	digest, err := dlutil.ParseDigest(dl.Sha)
 	if err != nil {
-		return nil, errutil.New("fsutil.URLFilename", err)
+		return nil, errutil.New("dlutil.ParseDigest", err)
+	}
+
+	locked, ok := lock.Artifact(dl.Key())
+	verify := ok && !update
+
+	if verify {
+		drift := filename != locked.Filename ||
+			digest.Algorithm == "sha256" && digest.Sum != strings.ToLower(locked.Sha)
+		if drift {
+			return nil, errutil.WithFramef("%w: %s", ErrLockDrift, dl.Key())
+		}
+	}
+
+	// sha is the sha256 of the artifact, which keys the lockfile and the cache.
+	sha := ""
+
+	switch {
+	case digest.Algorithm == "sha256":
+		sha = digest.Sum
+	case verify:
+		sha = strings.ToLower(locked.Sha)
 	}
 
-	if dl.Filename != "" {
-		filename = dl.Filename
+	if digest.IsZero() && sha != "" {
+		digest = dlutil.Digest{Algorithm: "sha256", Sum: sha}
+	}
+
+	// Checksum files are only fetched while the artifact is not pinned by the lockfile.
+	if digest.IsZero() && dl.ShaURL != "" {
+		digest, err = dlutil.FetchChecksum(ctx, client, dl.ShaURL, filename)
+		if err != nil {
+			return nil, errutil.New("dlutil.FetchChecksum", err)
+		}
+
+		if digest.Algorithm == "sha256" {
+			sha = digest.Sum
+		}
+	}
+
+	keys, err := publicKeys(dl, flags)
+	if err != nil {
+		return nil, errutil.WithFrame(err)
 	}
 
-	tarball := filepath.Join(dl.Dir, filename)
 	d := dlutil.Download{
-		URL:       dl.URL,
-		Directory: dl.Dir,
-		Filename:  filename,
-		SHA256:    dl.Sha,
+		Directory:  dl.Dir,
+		Filename:   filename,
+		Signature:  dl.Signature,
+		PublicKeys: keys,
+		Client:     client,
+	}
+
+	if !digest.IsZero() {
+		d.Checksum = digest.String()
+	}
+
+	// The validator below verifies the linked file, falling back to a download if it is corrupt.
+	if sha != "" && !fsutil.Exists(tarball) && cache.Has(sha) {
+		if err := cache.Link(sha, tarball); err != nil {
+			return nil, errutil.New("cache.Link", err)
+		}
+	}
+
+	validator := d.NewDefaultValidator
+	if update && digest.IsZero() {
+		validator = nil // Always fetch the current upstream file when refreshing the lockfile.
 	}
 
-	if err := d.Download(context.TODO(), messenger, d.NewDefaultValidator); err != nil {
+	urls := mirrorURLs(dl, flags.Mirrors)
+
+	for i, url := range urls {
+		d.URL = url
+
+		err = d.Download(ctx, messenger, validator)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+
+		if i+1 < len(urls) {
+			logger.Eventf(logutil.LevelInfo, "download",
+				logutil.Fields{"url": url, "next": urls[i+1], "error": err.Error()},
+				"Failed to download %s, trying %s: %v\n", url, urls[i+1], err)
+		}
+	}
+
+	if err != nil {
 		return nil, errutil.New("Download", err)
 	}
 
-	ext := filepath.Ext(filename)
-	if !slices.Contains(arcs, ext) && !dl.Force {
-		return nil, ErrNotArchive
+	sum, err := lockArtifact(lock, dl, tarball, verify)
+	if err != nil {
+		return nil, errutil.WithFrame(err)
 	}
 
-	extract := dl.Dir
-	if dl.Extract != "" {
-		extract = dl.Extract
+	if err := cache.Store(sum, tarball); err != nil {
+		return nil, errutil.New("cache.Store", err)
 	}
 
-	dir := filepath.Join(extract, fsutil.Filename(filename))
-	if fsutil.Exists(dir) {
+	if !p.Archive {
+		return nil, ErrNotArchive
+	}
+
+	if fsutil.Exists(p.Dir) {
 		return nil, ErrAlreadyExtracted
 	}
 
-	switch m {
-	case ExtractImmediately:
-		if err := unarchive(logger, tarball, dir); err != nil {
-			return nil, errutil.New("unarchive", err)
+	if m == ExtractNever {
+		return nil, ErrNeverExtracted
+	}
+
+	return &archiveJob{Tarball: tarball, Dir: p.Dir, Download: dl}, nil
+}
+
+// publicKeys returns the keys signatures of the artifact are verified against.
+func publicKeys(dl config.Download, flags *config.Flags) ([]ed25519.PublicKey, error) {
+	keys := []ed25519.PublicKey{}
+
+	for _, s := range slices.Concat(dl.PublicKeys, flags.Download.PublicKeys) {
+		key, err := dlutil.ParsePublicKey(s)
+		if err != nil {
+			return nil, errutil.New("dlutil.ParsePublicKey", err)
 		}
-	case ExtractAfterAll:
-		return &archiveJob{Tarball: tarball, Dir: dir}, nil
-	case ExtractNever:
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// lockArtifact records the downloaded file to the lockfile, returning its sha. If verify
+// is true, the file must match the existing entry.
+func lockArtifact(lock *config.Lockfile, dl config.Download, path string, verify bool) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", errutil.New("os.Stat", err)
+	}
+
+	sum, err := cryptoutil.NewSHA256(path)
+	if err != nil {
+		return "", errutil.New("cryptoutil.NewSHA256", err)
+	}
+
+	artifact := config.LockedArtifact{
+		ID:       dl.ID,
+		URL:      dl.URL,
+		Filename: filepath.Base(path),
+		Size:     info.Size(),
+		Sha:      sum,
 	}
 
-	return nil, ErrNeverExtracted
+	// The URL of an artifact with an id may change, and is refreshed.
+	if locked, ok := lock.Artifact(dl.Key()); verify && ok && !locked.Matches(artifact) {
+		return "", errutil.WithFramef("%w: %s", ErrLockDrift, dl.Key())
+	}
+
+	lock.SetArtifact(artifact)
+
+	return sum, nil
+}
+
+// extract extracts the job, stripping leading path components, and runs its post-extract actions.
+func (j *archiveJob) extract(logger *logutil.Logger) error {
+	if j.Download.Strip <= 0 {
+		if err := unarchive(logger, j.Tarball, j.Dir); err != nil {
+			return errutil.New("unarchive", err)
+		}
+	} else {
+		tmp := j.Dir + ".tmp"
+		if err := fsutil.RemoveAll(tmp); err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		if err := unarchive(logger, j.Tarball, tmp); err != nil {
+			return errutil.New("unarchive", err)
+		}
+
+		if err := stripComponents(tmp, j.Dir, j.Download.Strip); err != nil {
+			return errutil.New("stripComponents", err)
+		}
+	}
+
+	if err := postExtract(logger, j.Download); err != nil {
+		return errutil.New("postExtract", err)
+	}
+
+	return nil
 }
 
 // unarchive unarchives a tarball to a destination.
 func unarchive(logger *logutil.Logger, tarball, dst string) error {
-	logutil.Infof(logger, "Extracting %s to %s\n", tarball, dst)
+	logger.Eventf(logutil.LevelInfo, "extract", logutil.Fields{"tarball": tarball, "dst": dst},
+		"Extracting %s to %s\n", tarball, dst)
 
 	if err := arc.Unarchive(tarball, dst); err != nil {
 		return errutil.WithFrame(err)
@@ -1,28 +1,59 @@
 package custom
 
 import (
+	"errors"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/ricochhet/gpm/config"
 	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
 	"github.com/ricochhet/pkg/logutil"
 )
 
+var ErrUnknownArtifact = errors.New("unknown artifact")
+
+// BuiltinFunc is the function run for a builtin.
+type BuiltinFunc func(logger *logutil.Logger, flags config.Flags) error
+
 type Builtins struct {
 	// Builtins
 	Download string
 	Remove   string
 	// Internal
-	mu        sync.Mutex
-	artifacts config.Artifacts
+	mu         sync.Mutex
+	registry   map[string]BuiltinFunc
+	artifacts  config.Artifacts
+	lockfile   string
+	plugins    []config.Builtin
+	pluginsDir string
 }
 
 // NewDefaultBuiltins returns a default Builtins struct.
 func NewDefaultBuiltins() *Builtins {
-	return &Builtins{
-		Download: "gpm:pull",
-		Remove:   "gpm:prune",
+	c := &Builtins{
+		Download: BuiltinPrefix + "pull",
+		Remove:   BuiltinPrefix + "prune",
+		registry: map[string]BuiltinFunc{},
 	}
+
+	c.Register(c.Download, func(logger *logutil.Logger, flags config.Flags) error {
+		return c.pull(logger, &flags)
+	})
+	c.Register(c.Remove, func(logger *logutil.Logger, flags config.Flags) error {
+		return Remove(logger, c.artifacts.Prune, flags.DryRun)
+	})
+
+	return c
+}
+
+// Register adds, or replaces, the builtin by name.
+func (c *Builtins) Register(name string, fn BuiltinFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.registry[name] = fn
 }
 
 // SetArtifacts sets the artifacts.
@@ -33,7 +64,46 @@ func (c *Builtins) SetArtifacts(a config.Artifacts) {
 	c.artifacts = a
 }
 
-// Start checks if the name matches a known function.
+// SetLockfile sets the path of the lockfile artifacts are recorded to.
+func (c *Builtins) SetLockfile(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lockfile = path
+}
+
+// SetPlugins sets the builtins defined in the Taskfile, and the directory
+// searched for plugin executables before PATH.
+func (c *Builtins) SetPlugins(plugins []config.Builtin, dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.plugins = plugins
+	c.pluginsDir = dir
+}
+
+// Names returns the names of all builtins, including plugins.
+func (c *Builtins) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.registry))
+	for name := range c.registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range c.pluginNames() {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Start checks if the name matches a registered builtin or plugin.
 // If a function is known, execute, and return true. Otherwise return false.
 func (c *Builtins) Start(
 	logger *logutil.Logger,
@@ -47,15 +117,79 @@ func (c *Builtins) Start(
 		return true, errutil.WithFrame(err)
 	}
 
-	switch name {
-	case c.Download:
-		return true, errutil.WithFrame(
-			ExtractImmediately.Download(logger, c.artifacts.Pull, &flags))
-	case c.Remove:
-		return true, errutil.WithFrame(Remove(logger, c.artifacts.Prune))
-	default:
-		return false, nil
+	if fn, ok := c.registry[name]; ok {
+		return true, errutil.WithFrame(fn(logger, flags))
+	}
+
+	if p, ok := c.lookPlugin(name); ok {
+		err := p.run(logger, newPluginRequest(name, flags, c.artifacts))
+		if err != nil {
+			logger.Eventf(logutil.LevelError, "plugin", logutil.Fields{"plugin": name, "error": err.Error()},
+				"Failed to run %s: %v\n", name, err)
+		}
+
+		return true, errutil.WithFrame(err)
+	}
+
+	return false, nil
+}
+
+// Pulled returns true if the artifact with the key is recorded in the lockfile, and
+// its download or extraction directory exists.
+func (c *Builtins) Pulled(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := slices.IndexFunc(c.artifacts.Pull, func(dl config.Download) bool {
+		return dl.Key() == key
+	})
+	if i < 0 {
+		return false, errutil.WithFramef("%w: %s", ErrUnknownArtifact, key)
 	}
+
+	if c.lockfile != "" {
+		lock, err := config.ReadLockfile(c.lockfile)
+		if err != nil {
+			return false, errutil.WithFrame(err)
+		}
+
+		if _, ok := lock.Artifact(key); !ok {
+			return false, nil
+		}
+	}
+
+	p, err := newArtifactPaths(c.artifacts.Pull[i])
+	if err != nil {
+		return false, errutil.WithFrame(err)
+	}
+
+	return fsutil.Exists(p.Dir) || fsutil.Exists(p.Tarball), nil
+}
+
+// pull downloads all artifacts, updating the lockfile if any entry changed.
+func (c *Builtins) pull(logger *logutil.Logger, flags *config.Flags) error {
+	lock := &config.Lockfile{}
+
+	if c.lockfile != "" {
+		var err error
+
+		lock, err = config.ReadLockfile(c.lockfile)
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
+	artifacts := slices.Clone(lock.Artifacts)
+	err := ExtractImmediately.Download(logger, c.artifacts.Pull, lock, flags)
+
+	// Record artifacts that were downloaded, even if a later artifact failed.
+	if c.lockfile != "" && !slices.Equal(artifacts, lock.Artifacts) {
+		if err := lock.Write(c.lockfile); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
+	return errutil.WithFrame(err)
 }
 
 // ensureBuiltins ensures the builtin command values are not empty.
@@ -0,0 +1,189 @@
+package custom
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ricochhet/pkg/cryptoutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+)
+
+var ErrInvalidCacheKey = errors.New("invalid cache key")
+
+// Cache is a content-addressed store of downloaded artifacts, keyed by sha256.
+type Cache struct {
+	Dir string
+}
+
+type CacheEntry struct {
+	Sha     string
+	Size    int64
+	ModTime time.Time
+}
+
+// NewCache returns a Cache that stores artifacts within dir.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: filepath.Join(dir, "artifacts", "sha256")}
+}
+
+// Path returns the path of the artifact by sha.
+func (c *Cache) Path(sha string) string {
+	return filepath.Join(c.Dir, strings.ToLower(sha))
+}
+
+// Has returns true if the artifact by sha is cached.
+func (c *Cache) Has(sha string) bool {
+	return isCacheKey(sha) && fsutil.Exists(c.Path(sha))
+}
+
+// Link hard links, or copies, the artifact by sha to dst.
+func (c *Cache) Link(sha, dst string) error {
+	if err := fsutil.LinkOrCopy(c.Path(sha), dst); err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	return c.touch(sha)
+}
+
+// Store hard links, or copies, src into the cache by sha.
+func (c *Cache) Store(sha, src string) error {
+	if !isCacheKey(sha) {
+		return errutil.WithFramef("%w: %s", ErrInvalidCacheKey, sha)
+	}
+
+	if c.Has(sha) {
+		return c.touch(sha)
+	}
+
+	// Store through a temporary file so a partial copy is never addressable.
+	tmp := c.Path(sha) + ".tmp"
+	if err := fsutil.LinkOrCopy(src, tmp); err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	if err := os.Rename(tmp, c.Path(sha)); err != nil {
+		os.Remove(tmp)
+		return errutil.New("os.Rename", err)
+	}
+
+	return nil
+}
+
+// List returns every cached artifact.
+func (c *Cache) List() ([]CacheEntry, error) {
+	files, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errutil.New("os.ReadDir", err)
+	}
+
+	entries := make([]CacheEntry, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || !isCacheKey(file.Name()) {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return nil, errutil.New("file.Info", err)
+		}
+
+		entries = append(entries, CacheEntry{
+			Sha:     file.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	return entries, nil
+}
+
+// Verify returns every cached artifact whose content does not match its sha.
+func (c *Cache) Verify() ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, errutil.WithFrame(err)
+	}
+
+	corrupt := []CacheEntry{}
+
+	for _, entry := range entries {
+		sum, err := cryptoutil.NewSHA256(c.Path(entry.Sha))
+		if err != nil {
+			return nil, errutil.New("cryptoutil.NewSHA256", err)
+		}
+
+		if sum != entry.Sha {
+			corrupt = append(corrupt, entry)
+		}
+	}
+
+	return corrupt, nil
+}
+
+// Prune removes cached artifacts that are corrupt, or have not been used within maxAge.
+// If maxAge is zero, only corrupt artifacts are removed.
+func (c *Cache) Prune(maxAge time.Duration) ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, errutil.WithFrame(err)
+	}
+
+	corrupt, err := c.Verify()
+	if err != nil {
+		return nil, errutil.WithFrame(err)
+	}
+
+	removed := []CacheEntry{}
+
+	for _, entry := range entries {
+		expired := maxAge != 0 && time.Since(entry.ModTime) > maxAge
+		if !expired && !containsEntry(corrupt, entry) {
+			continue
+		}
+
+		if err := os.Remove(c.Path(entry.Sha)); err != nil {
+			return removed, errutil.New("os.Remove", err)
+		}
+
+		removed = append(removed, entry)
+	}
+
+	return removed, nil
+}
+
+// touch marks the artifact by sha as used.
+func (c *Cache) touch(sha string) error {
+	now := time.Now()
+	if err := os.Chtimes(c.Path(sha), now, now); err != nil {
+		return errutil.New("os.Chtimes", err)
+	}
+
+	return nil
+}
+
+// isCacheKey returns true if sha is a hex encoded sha256.
+func isCacheKey(sha string) bool {
+	b, err := hex.DecodeString(sha)
+	return err == nil && len(b) == 32
+}
+
+// containsEntry returns true if entries contains an entry with the same sha as entry.
+func containsEntry(entries []CacheEntry, entry CacheEntry) bool {
+	for _, e := range entries {
+		if e.Sha == entry.Sha {
+			return true
+		}
+	}
+
+	return false
+}
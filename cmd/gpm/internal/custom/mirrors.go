@@ -0,0 +1,43 @@
+package custom
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ricochhet/gpm/config"
+)
+
+// mirrorURLs returns the URLs the artifact is downloaded from, in the order they
+// are tried. A URL rewritten by the mirrors map is tried before the original.
+func mirrorURLs(dl config.Download, rewrites map[string]string) []string {
+	urls := []string{}
+
+	for _, url := range slices.Concat([]string{dl.URL}, dl.Mirrors) {
+		if rewritten, ok := rewriteURL(url, rewrites); ok && !slices.Contains(urls, rewritten) {
+			urls = append(urls, rewritten)
+		}
+
+		if !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+// rewriteURL replaces the longest matching prefix of url with its replacement.
+func rewriteURL(url string, rewrites map[string]string) (string, bool) {
+	prefix := ""
+
+	for p := range rewrites {
+		if strings.HasPrefix(url, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+
+	if prefix == "" {
+		return url, false
+	}
+
+	return rewrites[prefix] + strings.TrimPrefix(url, prefix), true
+}
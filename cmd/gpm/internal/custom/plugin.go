@@ -0,0 +1,177 @@
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+const (
+	// BuiltinPrefix is the prefix of every builtin name, e.g. 'gpm:pull'.
+	BuiltinPrefix = "gpm:"
+	// PluginPrefix is the prefix of plugin executables, e.g. 'gpm-deploy' for 'gpm:deploy'.
+	PluginPrefix = "gpm-"
+)
+
+var (
+	ErrPluginFailed = errors.New("plugin failed")
+	ErrPluginResult = errors.New("plugin returned an invalid result")
+)
+
+// PluginRequest is written as JSON to the stdin of a plugin.
+type PluginRequest struct {
+	Name      string           `json:"name"`
+	Flags     config.Flags     `json:"flags"`
+	Artifacts config.Artifacts `json:"artifacts"`
+}
+
+// newPluginRequest returns the request of the plugin. Plugins may be any executable
+// on PATH, so the RPC token is not passed on.
+func newPluginRequest(name string, flags config.Flags, artifacts config.Artifacts) PluginRequest {
+	flags.Token = ""
+
+	return PluginRequest{
+		Name:      name,
+		Flags:     flags,
+		Artifacts: artifacts,
+	}
+}
+
+// PluginResult is read as JSON from the stdout of a plugin. An empty stdout is
+// treated as success.
+type PluginResult struct {
+	Error    string   `json:"error"`
+	Messages []string `json:"messages"`
+	Warnings []string `json:"warnings"`
+}
+
+type plugin struct {
+	Name string
+	Cmd  []string
+	Dir  string
+}
+
+// lookPlugin returns the plugin by builtin name. Builtins defined in the Taskfile
+// take precedence over executables in the plugins directory, which take precedence
+// over executables on PATH.
+func (c *Builtins) lookPlugin(name string) (*plugin, bool) {
+	short, ok := strings.CutPrefix(name, BuiltinPrefix)
+	if !ok || short == "" {
+		return nil, false
+	}
+
+	for _, b := range c.plugins {
+		if BuiltinPrefix+b.Name == name && len(b.Cmd) != 0 {
+			return &plugin{Name: name, Cmd: b.Cmd, Dir: b.Dir}, true
+		}
+	}
+
+	if c.pluginsDir != "" {
+		if path, err := exec.LookPath(filepath.Join(c.pluginsDir, PluginPrefix+short)); err == nil {
+			return &plugin{Name: name, Cmd: []string{path}}, true
+		}
+	}
+
+	if path, err := exec.LookPath(PluginPrefix + short); err == nil {
+		return &plugin{Name: name, Cmd: []string{path}}, true
+	}
+
+	return nil, false
+}
+
+// pluginNames returns the builtin names of every plugin defined in the Taskfile,
+// or found within the plugins directory or on PATH.
+func (c *Builtins) pluginNames() []string {
+	names := []string{}
+
+	for _, b := range c.plugins {
+		names = append(names, BuiltinPrefix+b.Name)
+	}
+
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+	if c.pluginsDir != "" {
+		dirs = append([]string{c.pluginsDir}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			short, ok := strings.CutPrefix(entry.Name(), PluginPrefix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+
+			if runtime.GOOS == "windows" {
+				short = strings.TrimSuffix(short, filepath.Ext(short))
+			}
+
+			if _, err := exec.LookPath(filepath.Join(dir, entry.Name())); err != nil {
+				continue
+			}
+
+			names = append(names, BuiltinPrefix+short)
+		}
+	}
+
+	return names
+}
+
+// run runs the plugin, passing the request on stdin and logging the result.
+func (p *plugin) run(logger *logutil.Logger, req PluginRequest) error {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return errutil.New("json.Marshal", err)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd := exec.CommandContext(context.Background(), p.Cmd[0], p.Cmd[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Env = append(os.Environ(), "GPM_BUILTIN="+p.Name)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = logger
+
+	logger.Eventf(logutil.LevelDebug, "plugin", logutil.Fields{"plugin": p.Name}, "plugin: %s\n", cmd.String())
+
+	if err := cmd.Run(); err != nil {
+		return errutil.New("cmd.Run", err)
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil
+	}
+
+	var result PluginResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return errutil.WithFramef("%w: %s: %w", ErrPluginResult, p.Name, err)
+	}
+
+	for _, msg := range result.Messages {
+		logger.Eventf(logutil.LevelInfo, "plugin", logutil.Fields{"plugin": p.Name}, "%s\n", msg)
+	}
+
+	for _, msg := range result.Warnings {
+		logger.Eventf(logutil.LevelWarn, "plugin", logutil.Fields{"plugin": p.Name}, "%s\n", msg)
+	}
+
+	if result.Error != "" {
+		return errutil.WithFramef("%w: %s: %s", ErrPluginFailed, p.Name, result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package custom_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/custom"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+func TestPluginRequestToken(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a shell script")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat > \"$(dirname \"$0\")/request.json\"\n"
+
+	if err := os.WriteFile(filepath.Join(dir, custom.PluginPrefix+"dump"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	builtins := custom.NewDefaultBuiltins()
+	builtins.SetPlugins(nil, dir)
+
+	ok, err := builtins.Start(logutil.CreateLogger("test", 0), custom.BuiltinPrefix+"dump", config.Flags{Token: "secret"})
+	if !ok || err != nil {
+		t.Fatalf("got %v, %v, wanted the plugin to run", ok, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req custom.PluginRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatalf("got %q, wanted a plugin request: %v", b, err)
+	}
+
+	if req.Name != custom.BuiltinPrefix+"dump" {
+		t.Errorf("got name %q, wanted %q", req.Name, custom.BuiltinPrefix+"dump")
+	}
+
+	if req.Flags.Token != "" || bytes.Contains(b, []byte("secret")) {
+		t.Errorf("got request %s, wanted it without the token", b)
+	}
+}
@@ -0,0 +1,267 @@
+package custom
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ricochhet/pkg/byteutil"
+	"github.com/ricochhet/pkg/cmdutil"
+	"github.com/ricochhet/pkg/dlutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+const (
+	progressBarWidth    = 30
+	progressTTYInterval = 200 * time.Millisecond
+	progressLogInterval = 5 * time.Second
+)
+
+type transfer struct {
+	key     string // The key of the artifact, see config.Download.Key.
+	name    string
+	written int64
+	total   int64
+	start   time.Time
+}
+
+// progress aggregates the progress of concurrent downloads. In a terminal it
+// renders a progress bar per download, otherwise it periodically logs a line
+// per download. While rendering in a terminal, log lines are printed through the
+// receiver, above the progress bars.
+type progress struct {
+	mu        sync.Mutex
+	logger    *logutil.Logger
+	tty       io.Writer
+	transfers []*transfer
+	lines     int
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newProgress creates a progress and starts rendering it.
+func newProgress(logger *logutil.Logger) *progress {
+	p := &progress{
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	interval := progressLogInterval
+	if cmdutil.IsTerminal(os.Stdout) {
+		p.tty = logutil.SetWriter(p)
+		interval = progressTTYInterval
+	}
+
+	go p.run(interval)
+
+	return p
+}
+
+// Stop stops rendering, drawing the final state.
+func (p *progress) Stop() {
+	close(p.stop)
+	<-p.done
+
+	if p.tty != nil {
+		logutil.SetWriter(p.tty)
+	}
+}
+
+// Write writes a log line above the progress bars, which are drawn again on the next
+// render.
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lines > 0 {
+		fmt.Fprintf(p.tty, "\x1b[%dA\x1b[J", p.lines)
+		p.lines = 0
+	}
+
+	n, err := p.tty.Write(b)
+	if err != nil {
+		return n, errutil.New("p.tty.Write", err)
+	}
+
+	return n, nil
+}
+
+// messenger returns a dlutil.Messenger that reports the transfer of the artifact
+// by key to the receiver, as artifacts in different directories may share a name.
+// Transfers are shown by their path in dir.
+func (p *progress) messenger(key, dir string) dlutil.Messenger {
+	return dlutil.Messenger{
+		Start: func(name string) {
+			p.start(key, filepath.ToSlash(filepath.Join(dir, name)))
+		},
+		Progress: func(_ string, written, total int64) {
+			p.update(key, written, total)
+		},
+		Done: func(string) {
+			p.finish(key)
+		},
+	}
+}
+
+// start adds, or resets, the transfer by key.
+func (p *progress) start(key, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tty == nil {
+		logutil.Infof(p.logger, "Downloading: %s\n", name)
+	}
+
+	if t := p.find(key); t != nil {
+		t.name, t.written, t.total, t.start = name, 0, -1, time.Now()
+		return
+	}
+
+	p.transfers = append(p.transfers, &transfer{key: key, name: name, total: -1, start: time.Now()})
+}
+
+// update sets the bytes written of the transfer by key.
+func (p *progress) update(key string, written, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t := p.find(key); t != nil {
+		t.written, t.total = written, total
+	}
+}
+
+// finish logs the completed transfer by key.
+func (p *progress) finish(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.find(key)
+	if t == nil {
+		return
+	}
+
+	t.total = t.written
+
+	if p.tty == nil {
+		elapsed := time.Since(t.start).Round(time.Millisecond)
+		logutil.Infof(p.logger, "Downloaded: %s (%s in %s)\n",
+			t.name, byteutil.FormatSize(t.written), elapsed)
+	}
+}
+
+// run renders the receiver every interval, until stopped.
+func (p *progress) run(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			p.render(true)
+			return
+		case <-ticker.C:
+			p.render(false)
+		}
+	}
+}
+
+// render draws every transfer. Completed transfers are only logged once.
+func (p *progress) render(final bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tty == nil {
+		if final {
+			return
+		}
+
+		for _, t := range p.transfers {
+			if t.written != t.total {
+				logutil.Infof(p.logger, "%s: %s\n", t.name, t.status())
+			}
+		}
+
+		return
+	}
+
+	var b strings.Builder
+
+	if p.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+
+	width := 0
+	for _, t := range p.transfers {
+		width = max(width, len(t.name))
+	}
+
+	for _, t := range p.transfers {
+		fmt.Fprintf(&b, "\x1b[2K%-*s %s %s\n", width, t.name, t.bar(), t.status())
+	}
+
+	p.lines = len(p.transfers)
+
+	if _, err := io.WriteString(p.tty, b.String()); err != nil {
+		logutil.Errorf(os.Stderr, "Failed to render progress: %v\n", err)
+	}
+}
+
+// find returns the transfer by key, or nil.
+func (p *progress) find(key string) *transfer {
+	for _, t := range p.transfers {
+		if t.key == key {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// bar returns the progress bar of the receiver.
+func (t *transfer) bar() string {
+	filled := 0
+	if t.total > 0 {
+		filled = int(t.written * progressBarWidth / t.total)
+	}
+
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+}
+
+// status returns the percentage, bytes, rate and ETA of the receiver.
+func (t *transfer) status() string {
+	elapsed := time.Since(t.start).Seconds()
+
+	rate := int64(0)
+	if elapsed > 0 {
+		rate = int64(float64(t.written) / elapsed)
+	}
+
+	if t.total <= 0 {
+		return fmt.Sprintf("%s %s/s", byteutil.FormatSize(t.written), byteutil.FormatSize(rate))
+	}
+
+	if t.written == t.total {
+		return fmt.Sprintf("100%% %s", byteutil.FormatSize(t.total))
+	}
+
+	eta := "?"
+	if rate > 0 {
+		eta = (time.Duration((t.total-t.written)/rate) * time.Second).String()
+	}
+
+	return fmt.Sprintf("%3d%% %s/%s %s/s ETA %s",
+		t.written*100/t.total,
+		byteutil.FormatSize(t.written),
+		byteutil.FormatSize(t.total),
+		byteutil.FormatSize(rate),
+		eta,
+	)
+}
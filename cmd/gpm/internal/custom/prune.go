@@ -0,0 +1,174 @@
+package custom
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/byteutil"
+	"github.com/ricochhet/pkg/cryptoutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+type pruneCandidate struct {
+	Path    string
+	ModTime time.Time
+}
+
+// Remove attempts to remove all files matching the files slice. Names may be glob
+// patterns, narrowed by exclusions, keep and max age rules. If a SHA256 is specified,
+// a file will only be removed if the hash matches. If dryRun is true, files that
+// would be removed are only listed.
+func Remove(logger *logutil.Logger, files []config.File, dryRun bool) error {
+	var (
+		count int
+		total int64
+	)
+
+	verb := "Removing"
+	if dryRun {
+		verb = "Would remove"
+	}
+
+	for _, file := range files {
+		paths, err := pruneCandidates(file)
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		for _, path := range paths {
+			size, err := fsutil.Size(path)
+			if err != nil {
+				return errutil.New("fsutil.Size", err)
+			}
+
+			logger.Eventf(logutil.LevelInfo, "remove", logutil.Fields{"path": path, "size": size},
+				"%s: %s (%s)\n", verb, path, byteutil.FormatSize(size))
+
+			if dryRun {
+				count++
+				total += size
+
+				continue
+			}
+
+			if err := fsutil.RemoveAll(path); err != nil {
+				logger.Eventf(logutil.LevelInfo, "remove", logutil.Fields{"path": path, "error": err.Error()},
+					"Failed to remove file: %s\n", path)
+				continue
+			}
+
+			count++
+			total += size
+		}
+	}
+
+	fields := logutil.Fields{"count": count, "size": total, "dryRun": dryRun}
+
+	if dryRun {
+		logger.Eventf(logutil.LevelInfo, "prune", fields,
+			"Would remove %d paths, reclaiming %s\n", count, byteutil.FormatSize(total))
+	} else {
+		logger.Eventf(logutil.LevelInfo, "prune", fields,
+			"Removed %d paths, reclaimed %s\n", count, byteutil.FormatSize(total))
+	}
+
+	return nil
+}
+
+// pruneCandidates returns the paths matching the file that should be removed.
+func pruneCandidates(file config.File) ([]string, error) {
+	pattern, err := fsutil.FromCwd(file.Name)
+	if err != nil {
+		return nil, errutil.New("fsutil.FromCwd", err)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errutil.New("filepath.Glob", err)
+	}
+
+	var maxAge time.Duration
+
+	if file.MaxAge != "" {
+		maxAge, err = time.ParseDuration(file.MaxAge)
+		if err != nil {
+			return nil, errutil.New("time.ParseDuration", err)
+		}
+	}
+
+	candidates := []pruneCandidate{}
+
+	for _, path := range matches {
+		if excluded(path, file.Exclude) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errutil.New("os.Stat", err)
+		}
+
+		if file.Sha != "" {
+			if info.IsDir() {
+				continue
+			}
+
+			sum, err := cryptoutil.NewSHA256(path)
+			if err != nil {
+				return nil, errutil.New("cryptoutil.NewSHA256", err)
+			}
+
+			if file.Sha != sum {
+				continue
+			}
+		}
+
+		candidates = append(candidates, pruneCandidate{Path: path, ModTime: info.ModTime()})
+	}
+
+	// Newest first, so the first keep candidates are retained.
+	slices.SortFunc(candidates, func(a, b pruneCandidate) int {
+		return b.ModTime.Compare(a.ModTime)
+	})
+
+	paths := []string{}
+
+	for i, c := range candidates {
+		if i < file.Keep {
+			continue
+		}
+
+		if maxAge != 0 && time.Since(c.ModTime) < maxAge {
+			continue
+		}
+
+		paths = append(paths, c.Path)
+	}
+
+	return paths, nil
+}
+
+// excluded returns true if the path, or its base name, matches any of the patterns.
+func excluded(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+
+		abs, err := fsutil.FromCwd(pattern)
+		if err != nil {
+			continue
+		}
+
+		if ok, _ := filepath.Match(abs, path); ok {
+			return true
+		}
+	}
+
+	return false
+}
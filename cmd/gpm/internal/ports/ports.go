@@ -0,0 +1,183 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ricochhet/pkg/cueutil"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/fsutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// Step is the number of ports reserved per task, starting from the base port.
+const Step = 100
+
+var ErrNoFreePort = errors.New("no free port")
+
+// Allocator assigns ports to tasks, and persists the assignments so a task keeps
+// its ports across restarts.
+type Allocator struct {
+	// Ports maps a task to its ports, by environment variable name, e.g. 'PORT_HTTP'.
+	Ports map[string]map[string]uint `json:"ports"`
+
+	base    uint
+	verify  bool
+	keep    map[string]bool // Tasks whose ports are in use by their own process.
+	changed bool
+}
+
+// Read reads the allocator state from path, returning an empty Allocator if it does
+// not exist. If verify is true, persisted ports that are in use are reassigned.
+func Read(path string, base uint, verify bool) (*Allocator, error) {
+	a := &Allocator{Ports: map[string]map[string]uint{}}
+
+	if fsutil.Exists(path) {
+		if _, err := cueutil.NewDefaultUnmarshal[Allocator]().File(path, a); err != nil {
+			return nil, errutil.New("cueutil.File", err)
+		}
+	}
+
+	if a.Ports == nil {
+		a.Ports = map[string]map[string]uint{}
+	}
+
+	a.base = base
+	a.verify = verify
+	a.keep = map[string]bool{}
+
+	return a, nil
+}
+
+// Keep keeps the persisted ports of the task, without checking if they are in use. It
+// is used for tasks whose process is still running, and will be re-adopted.
+func (a *Allocator) Keep(task string) {
+	a.keep[task] = true
+}
+
+// Write writes the receiver to path, if any assignment changed.
+func (a *Allocator) Write(path string) error {
+	if !a.changed {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errutil.New("os.MkdirAll", err)
+	}
+
+	if err := cueutil.NewDefaultMarshal[*Allocator]().File(path, a); err != nil {
+		return errutil.New("cueutil.File", err)
+	}
+
+	logutil.Debugf(os.Stdout, "wrote port map: %s\n", path)
+
+	a.changed = false
+
+	return nil
+}
+
+// EnvName returns the environment variable of a named port, 'PORT' if name is empty.
+func EnvName(name string) string {
+	if name == "" {
+		return "PORT"
+	}
+
+	return "PORT_" + strings.ToUpper(name)
+}
+
+// Allocate returns the ports of the task by environment variable name: 'PORT', and one
+// for each name. New ports are taken from the block of the task at index, skipping
+// ports that are assigned to another task, or are in use.
+func (a *Allocator) Allocate(task string, index int, names []string) (map[string]uint, error) {
+	assigned, ok := a.Ports[task]
+	if !ok {
+		assigned = map[string]uint{}
+		a.Ports[task] = assigned
+	}
+
+	keys := []string{EnvName("")}
+	for _, name := range names {
+		keys = append(keys, EnvName(name))
+	}
+
+	for key := range assigned {
+		if !contains(keys, key) {
+			delete(assigned, key)
+
+			a.changed = true
+		}
+	}
+
+	for i, key := range keys {
+		if port, ok := assigned[key]; ok {
+			if !a.verify || a.keep[task] || Available(port) {
+				continue
+			}
+
+			logutil.Warnf(os.Stdout, "Port %d (%s %s) is in use, reassigning\n", port, task, key)
+			delete(assigned, key)
+		}
+
+		port, err := a.next(a.base + Step*uint(index) + uint(i))
+		if err != nil {
+			return nil, errutil.WithFramef("%w: %s %s", err, task, key)
+		}
+
+		assigned[key] = port
+		a.changed = true
+	}
+
+	return maps.Clone(assigned), nil
+}
+
+// next returns the first port from start that is not assigned, and is available.
+func (a *Allocator) next(start uint) (uint, error) {
+	for port := start; port <= 65535; port++ {
+		if !a.assigned(port) && Available(port) {
+			return port, nil
+		}
+	}
+
+	return 0, ErrNoFreePort
+}
+
+// assigned returns true if the port is assigned to any task.
+func (a *Allocator) assigned(port uint) bool {
+	for _, ports := range a.Ports {
+		for _, p := range ports {
+			if p == port {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Available returns true if the TCP port can be listened on.
+func Available(port uint) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+
+	l.Close()
+
+	return true
+}
+
+// contains returns true if keys contains key.
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
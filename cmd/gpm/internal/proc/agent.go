@@ -0,0 +1,303 @@
+package proc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/rpc"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// agentInterval is how often an agent registers with its controller, so a restarted
+// controller learns of it again.
+const agentInterval = 10 * time.Second
+
+var (
+	ErrUnknownAgent = errors.New("unknown agent")
+	ErrInvalidAgent = errors.New("agent has no name or address")
+	ErrSelfAgent    = errors.New("agent is the controller itself")
+)
+
+// Agent is a gpm instance that registered with a controller. Its tasks are addressed
+// as 'name:task' through the controller.
+type Agent struct {
+	Name string
+	Addr string // RPC address of the agent.
+}
+
+// Agents holds the RPC addresses of registered agents by name.
+type Agents struct {
+	mu   sync.Mutex
+	list map[string]string
+}
+
+// NewAgents creates an empty Agents.
+func NewAgents() *Agents {
+	return &Agents{list: map[string]string{}}
+}
+
+// set registers the agent, and returns true if it is new or its address changed.
+func (a *Agents) set(agent Agent) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.list[agent.Name] == agent.Addr {
+		return false
+	}
+
+	a.list[agent.Name] = agent.Addr
+
+	return true
+}
+
+// addr returns the RPC address of the agent.
+func (a *Agents) addr(name string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	addr, ok := a.list[name]
+
+	return addr, ok
+}
+
+// names returns the sorted names of the registered agents.
+func (a *Agents) names() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(a.list))
+}
+
+// Register registers an agent with the controller.
+func (r *Gpm) Register(agent Agent, _ *string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			}
+		}
+	}()
+
+	if agent.Name == "" || agent.Addr == "" {
+		return errutil.WithFrame(ErrInvalidAgent)
+	}
+
+	if r.isOwnAddr(agent.Addr) {
+		return errutil.WithFramef("%w: %s", ErrSelfAgent, agent.Addr)
+	}
+
+	// Agents run tasks on behalf of the controller, they must have authenticated.
+	if config.RPCToken(r.ctx.Flags.Token) == "" {
+		return errutil.WithFramef("%w: the controller has no token", ErrTokenRequired)
+	}
+
+	if r.ctx.Agents.set(agent) {
+		logutil.Infof(os.Stdout, "Agent %s registered (%s)\n", agent.Name, agent.Addr)
+	}
+
+	return nil
+}
+
+// isOwnAddr returns true if the address is the listener of the server: its port, on a
+// loopback address or an address of this host.
+func (r *Gpm) isOwnAddr(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port != strconv.FormatUint(uint64(r.port), 10) {
+		return false
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+
+	// Without the interface addresses, only loopback addresses are matched.
+	local, _ := net.InterfaceAddrs()
+
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			return true
+		}
+
+		for _, a := range local {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// splitTargets splits the targets into local tasks, and tasks of registered agents by
+// agent name. A target of an agent is named 'agent:task', 'agent:' targets the agent
+// itself. Any other target, including names with a colon, is local.
+func (ctx *Context) splitTargets(targets []string) ([]string, map[string][]string) {
+	local := []string{}
+	remote := map[string][]string{}
+
+	for _, target := range targets {
+		name, task, ok := strings.Cut(target, ":")
+		if !ok {
+			local = append(local, target)
+			continue
+		}
+
+		if _, ok := ctx.Agents.addr(name); !ok {
+			local = append(local, target)
+			continue
+		}
+
+		tasks := remote[name]
+		if task != "" {
+			tasks = append(tasks, task)
+		}
+
+		remote[name] = tasks
+	}
+
+	return local, remote
+}
+
+// callAgent calls the RPC method of the agent.
+func (ctx *Context) callAgent(name, method string, args []string, ret *string) error {
+	addr, ok := ctx.Agents.addr(name)
+	if !ok {
+		return errutil.WithFramef("%w: %s", ErrUnknownAgent, name)
+	}
+
+	conn, err := Dial(addr, ctx.Flags.Token)
+	if err != nil {
+		return errutil.New("agent %s", err, name)
+	}
+
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	if err := client.Call(method, args, ret); err != nil {
+		return errutil.New("agent %s", err, name)
+	}
+
+	return nil
+}
+
+// forward calls the RPC method of every agent with its tasks.
+func (ctx *Context) forward(method string, remote map[string][]string) error {
+	for _, name := range slices.Sorted(maps.Keys(remote)) {
+		if len(remote[name]) == 0 {
+			continue
+		}
+
+		var ret string
+		if err := ctx.callAgent(name, method, remote[name], &ret); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
+	return nil
+}
+
+// agentStatus returns the status of the tasks of the agent, all if there are none,
+// with task names prefixed by the agent name.
+func (ctx *Context) agentStatus(name string, tasks []string) string {
+	var ret string
+	if err := ctx.callAgent(name, "Gpm.LocalStatus", tasks, &ret); err != nil {
+		logutil.Debugf(os.Stdout, "agent %s: %v\n", name, err)
+		return fmt.Sprintf(" %s: (unreachable)\n", name)
+	}
+
+	var sb strings.Builder
+
+	for line := range strings.Lines(ret) {
+		sb.WriteString(line[:1] + name + ":" + line[1:])
+	}
+
+	return sb.String()
+}
+
+// registerAgent registers with the controller, and again every agentInterval until
+// the context is done.
+func (ctx *Context) registerAgent(rpcCtx context.Context, cfg *config.Flags) {
+	agent, err := newAgent(cfg)
+	if err != nil {
+		logutil.Errorf(os.Stderr, "Failed to register with controller: %v\n", err)
+		return
+	}
+
+	var registered, warned bool
+
+	for {
+		err := register(cfg.Controller, cfg.Token, agent)
+
+		switch {
+		case err != nil && !warned:
+			logutil.Warnf(os.Stderr, "Failed to register with controller %s: %v\n", cfg.Controller, err)
+
+			registered, warned = false, true
+		case err == nil && !registered:
+			logutil.Infof(os.Stdout, "Registered as agent %s with controller %s\n", agent.Name, cfg.Controller)
+
+			registered, warned = true, false
+		}
+
+		select {
+		case <-rpcCtx.Done():
+			return
+		case <-time.After(agentInterval):
+		}
+	}
+}
+
+// newAgent returns the agent of this instance, named by its hostname and reached at
+// its RPC port unless set by the flags.
+func newAgent(cfg *config.Flags) (Agent, error) {
+	agent := Agent{Name: cfg.AgentName, Addr: cfg.AgentAddr}
+
+	if agent.Name != "" && agent.Addr != "" {
+		return agent, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return agent, errutil.New("os.Hostname", err)
+	}
+
+	if agent.Name == "" {
+		agent.Name = hostname
+	}
+
+	if agent.Addr == "" {
+		agent.Addr = net.JoinHostPort(hostname, strconv.FormatUint(uint64(cfg.Port), 10))
+	}
+
+	return agent, nil
+}
+
+// register registers the agent with the controller.
+func register(controller, token string, agent Agent) error {
+	conn, err := Dial(controller, token)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	var ret string
+	if err := client.Call("Gpm.Register", agent, &ret); err != nil {
+		return errutil.New("client.Call (Gpm.Register)", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package proc_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricochhet/gpm/internal/proc"
+	"github.com/ricochhet/gpm/internal/proctest"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// controllerFlags makes the supervisor of the harness both a controller, and an agent
+// registered with it at addr, its own address if empty.
+func controllerFlags(t *testing.T, h *proctest.Harness, addr string) {
+	t.Helper()
+
+	own := fmt.Sprintf("127.0.0.1:%d", h.Port)
+	if addr == "" {
+		addr = own
+	}
+
+	h.Ctx.Flags.Controller = own
+	h.Ctx.Flags.AgentName = "agent"
+	h.Ctx.Flags.AgentAddr = addr
+}
+
+// proxy forwards connections from a loopback port to target, and returns its address.
+// An agent reached through it is not recognized as the controller itself.
+func proxy(t *testing.T, target string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+
+				go io.Copy(upstream, conn) //nolint:errcheck // ends with either connection
+
+				io.Copy(conn, upstream) //nolint:errcheck // ends with either connection
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// waitMessage waits until a message starting with prefix is printed.
+func waitMessage(t *testing.T, h *proctest.Harness, prefix string) {
+	t.Helper()
+
+	deadline := time.Now().Add(proctest.Timeout)
+
+	for !slices.ContainsFunc(h.Entries(), func(e logutil.Entry) bool {
+		return strings.HasPrefix(e.Message, prefix)
+	}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got no message %q", prefix)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestControllerRequiresToken(t *testing.T) {
+	h := proctest.New(t, readyTasks("a"), rpcFlags())
+	controllerFlags(t, h, "")
+
+	h.Start("a")
+
+	if err := h.Wait(); !errors.Is(err, proc.ErrTokenRequired) {
+		t.Errorf("got %v, wanted %v", err, proc.ErrTokenRequired)
+	}
+}
+
+func TestRegisterRequiresToken(t *testing.T) {
+	h := proctest.New(t, readyTasks("a"), rpcFlags())
+
+	h.Start("a")
+
+	client, err := rpc.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", h.Port))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	var ret string
+
+	err = client.Call("Gpm.Register", proc.Agent{Name: "agent", Addr: "127.0.0.1:1"}, &ret)
+	if err == nil || !strings.Contains(err.Error(), proc.ErrTokenRequired.Error()) {
+		t.Errorf("got %v, wanted %v", err, proc.ErrTokenRequired)
+	}
+}
+
+func TestControllerAgent(t *testing.T) {
+	flags := rpcFlags()
+	flags.Token = "secret"
+
+	h := proctest.New(t, readyTasks("a"), flags)
+	controllerFlags(t, h, proxy(t, fmt.Sprintf("127.0.0.1:%d", h.Port)))
+
+	h.Start("a")
+	h.WaitEvent("a", "ready", 1)
+
+	// The agent registers asynchronously.
+	waitMessage(t, h, "Agent agent registered")
+
+	// The agent is its own controller, its status only lists its local tasks.
+	status, err := h.Call("Status")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+
+	if strings.Count(status, "agent:a") != 1 || strings.Contains(status, "agent:agent:") {
+		t.Errorf("got status %q, wanted the agent listed once", status)
+	}
+
+	if _, err := h.Call("Stop", "agent:a"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	h.WaitEvent("a", "exit", 1)
+
+	if _, err := proc.Dial(fmt.Sprintf("127.0.0.1:%d", h.Port), "wrong"); !errors.Is(err, proc.ErrUnauthorized) {
+		t.Errorf("got %v, wanted %v", err, proc.ErrUnauthorized)
+	}
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+}
+
+func TestSelfRegisteredAgent(t *testing.T) {
+	flags := rpcFlags()
+	flags.Token = "secret"
+
+	h := proctest.New(t, readyTasks("a"), flags)
+	controllerFlags(t, h, "")
+
+	h.Start("a")
+	h.WaitEvent("a", "ready", 1)
+
+	waitMessage(t, h, "Failed to register with controller")
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := h.Call("Status")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("status: %v", err)
+		}
+	case <-time.After(proctest.Timeout):
+		t.Fatal("status did not return")
+	}
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+}
@@ -0,0 +1,309 @@
+package proc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// attachPreamble starts an attach request on the control connection, followed by
+// the task name and a newline. Any other connection is served as RPC.
+const attachPreamble = "GPM/ATTACH "
+
+// detachKey detaches the local terminal from the task (Ctrl-]).
+const detachKey = 0x1d
+
+// attachWriteTimeout drops an attached client that does not keep up with the task output.
+const attachWriteTimeout = 5 * time.Second
+
+// Frames sent from the client to the server: a type byte, a big-endian uint16 length
+// and the payload.
+const (
+	frameData   byte = 'd'
+	frameResize byte = 'r' // Payload is rows and columns, as big-endian uint16s.
+)
+
+var (
+	ErrNotAttachable     = errors.New("task is not running on a pty")
+	ErrAttachUnsupported = errors.New("attach is not supported on this platform")
+	ErrAttachRefused     = errors.New("attach refused")
+)
+
+// Terminals holds the PTYs of running tasks by name.
+type Terminals struct {
+	mu   sync.Mutex
+	list map[string]*terminal
+}
+
+// terminal is the master side of a task PTY. Output is written to the task logger,
+// and copied to every attached client. Clients stay attached while the task restarts.
+type terminal struct {
+	mu      sync.Mutex
+	master  *os.File // Nil between runs of the task.
+	logger  io.Writer
+	clients map[net.Conn]struct{}
+}
+
+// NewTerminals creates an empty Terminals.
+func NewTerminals() *Terminals {
+	return &Terminals{list: map[string]*terminal{}}
+}
+
+// add registers the PTY master of the task. Clients attached to the previous PTY of a
+// restarted task are moved to the new one.
+func (t *Terminals) add(name string, master *os.File, logger io.Writer) *terminal {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	term, ok := t.list[name]
+	if !ok {
+		term = &terminal{clients: map[net.Conn]struct{}{}}
+		t.list[name] = term
+	}
+
+	term.mu.Lock()
+	term.master, term.logger = master, logger
+	term.mu.Unlock()
+
+	return term
+}
+
+// remove unregisters the terminal of the task once it stopped, and disconnects its
+// clients.
+func (t *Terminals) remove(name string) {
+	t.mu.Lock()
+	term, ok := t.list[name]
+	delete(t.list, name)
+	t.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	term.mu.Lock()
+	defer term.mu.Unlock()
+
+	for conn := range term.clients {
+		conn.Close()
+		delete(term.clients, conn)
+	}
+}
+
+// get returns the terminal of the task by name.
+func (t *Terminals) get(name string) (*terminal, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	term, ok := t.list[name]
+
+	return term, ok
+}
+
+// Write writes task output to the logger, and to every attached client.
+func (t *terminal) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for conn := range t.clients {
+		if err := conn.SetWriteDeadline(time.Now().Add(attachWriteTimeout)); err == nil {
+			if _, err := conn.Write(b); err == nil {
+				continue
+			}
+		}
+
+		conn.Close()
+		delete(t.clients, conn)
+	}
+
+	return t.logger.Write(b)
+}
+
+// release unsets the PTY master, once the run of the task it belongs to exited.
+func (t *terminal) release(master *os.File) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.master == master {
+		t.master = nil
+	}
+}
+
+// current returns the PTY master of the running task, or nil between runs.
+func (t *terminal) current() *os.File {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.master
+}
+
+// attach adds the client, and copies its input to the PTY until it detaches.
+func (t *terminal) attach(conn net.Conn, r io.Reader) error {
+	t.mu.Lock()
+	t.clients[conn] = struct{}{}
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.clients, conn)
+		t.mu.Unlock()
+	}()
+
+	header := make([]byte, 3)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return errutil.New("io.ReadFull", err)
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint16(header[1:]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return errutil.New("io.ReadFull", err)
+		}
+
+		// Input is dropped while the task restarts.
+		master := t.current()
+		if master == nil {
+			continue
+		}
+
+		switch header[0] {
+		case frameData:
+			if _, err := master.Write(payload); err != nil && !errors.Is(err, os.ErrClosed) {
+				return errutil.New("master.Write", err)
+			}
+		case frameResize:
+			if len(payload) != 4 {
+				continue
+			}
+
+			rows, cols := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+			if err := setSize(master, rows, cols); err != nil {
+				return errutil.WithFrame(err)
+			}
+		}
+	}
+}
+
+// serveConn serves an attach request if the connection starts with the attach
+// preamble, otherwise the connection is served as RPC.
+func (ctx *Context) serveConn(conn net.Conn, serveRPC func(io.ReadWriteCloser)) {
+	r := bufio.NewReader(conn)
+
+	if !ctx.authenticate(conn, r) {
+		logutil.Debugf(os.Stdout, "refused connection from %s\n", conn.RemoteAddr())
+		conn.Close()
+
+		return
+	}
+
+	if b, err := r.Peek(len(attachPreamble)); err != nil || string(b) != attachPreamble {
+		serveRPC(&bufferedConn{Conn: conn, r: r})
+		return
+	}
+
+	defer conn.Close()
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	// Attach gives keyboard input to the task, remote clients must authenticate.
+	if config.RPCToken(ctx.Flags.Token) == "" && !isLoopback(conn.RemoteAddr()) {
+		fmt.Fprintf(conn, "ERR %s: remote attach requires -token\n", ErrUnauthorized)
+		return
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(line, attachPreamble))
+	if proc := ctx.FindProc(name); proc != nil {
+		name = proc.Name
+	}
+
+	term, ok := ctx.Terminals.get(name)
+	if !ok {
+		fmt.Fprintf(conn, "ERR %s: %s\n", ErrNotAttachable, name)
+		return
+	}
+
+	if _, err := io.WriteString(conn, "OK\n"); err != nil {
+		return
+	}
+
+	if err := term.attach(conn, r); err != nil {
+		logutil.Debugf(os.Stdout, "attach %s: %v\n", name, err)
+	}
+}
+
+// bufferedConn reads through the buffered reader used to peek the preamble.
+type bufferedConn struct {
+	net.Conn
+
+	r *bufio.Reader
+}
+
+// Read implements io.Reader.
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// writeFrame writes the payload to the control connection, split into frames of at most 64 KiB.
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	for {
+		n := min(len(payload), 0xffff)
+
+		header := []byte{typ, 0, 0}
+		binary.BigEndian.PutUint16(header[1:], uint16(n))
+
+		if _, err := w.Write(append(header, payload[:n]...)); err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		payload = payload[n:]
+		if len(payload) == 0 {
+			return nil
+		}
+	}
+}
+
+// dialAttach connects to the control connection, and requests to attach to the task.
+func dialAttach(name, server, token string) (net.Conn, *bufio.Reader, error) {
+	conn, err := Dial(server, token)
+	if err != nil {
+		return nil, nil, errutil.WithFrame(err)
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s%s\n", attachPreamble, name); err != nil {
+		conn.Close()
+		return nil, nil, errutil.WithFrame(err)
+	}
+
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, errutil.New("r.ReadString", err)
+	}
+
+	if line = strings.TrimSpace(line); line != "OK" {
+		conn.Close()
+		return nil, nil, errutil.WithFramef("%w: %s", ErrAttachRefused, strings.TrimPrefix(line, "ERR "))
+	}
+
+	return conn, r, nil
+}
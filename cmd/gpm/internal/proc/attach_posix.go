@@ -0,0 +1,148 @@
+//go:build !windows
+// +build !windows
+
+package proc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/creack/pty"
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"golang.org/x/sys/unix"
+)
+
+// Attach connects the local terminal to the PTY of the task, until the detach key is pressed.
+func Attach(name string, serverPort uint, token string) error {
+	conn, r, err := dialAttach(name, config.DefaultServer(serverPort), token)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+	defer conn.Close()
+
+	fd := int(os.Stdin.Fd())
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+	defer restore()
+
+	fmt.Fprintf(os.Stdout, "Attached to %s, press Ctrl-] to detach.\r\n", name)
+
+	resize := func() {
+		ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+		if err != nil {
+			return
+		}
+
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint16(payload, ws.Row)
+		binary.BigEndian.PutUint16(payload[2:], ws.Col)
+
+		writeFrame(conn, frameResize, payload) //nolint:errcheck // best effort
+	}
+
+	resize()
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, unix.SIGWINCH)
+
+	defer signal.Stop(winch)
+
+	go func() {
+		for range winch {
+			resize()
+		}
+	}()
+
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+
+		io.Copy(os.Stdout, r) //nolint:errcheck // ends when the task exits, or on detach
+	}()
+
+	input := make(chan error, 1)
+
+	go func() {
+		buf := make([]byte, 4096)
+
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				input <- errutil.New("os.Stdin.Read", err)
+				return
+			}
+
+			b := buf[:n]
+
+			i := slices.Index(b, detachKey)
+			if i != -1 {
+				b = b[:i]
+			}
+
+			if err := writeFrame(conn, frameData, b); err != nil {
+				input <- err
+				return
+			}
+
+			if i != -1 {
+				input <- nil
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-input:
+		fmt.Fprintf(os.Stdout, "\r\nDetached from %s.\r\n", name)
+		return err
+	case <-exited:
+		fmt.Fprintf(os.Stdout, "\r\n%s exited.\r\n", name)
+		return nil
+	}
+}
+
+// makeRaw puts the terminal into raw mode, returning a function that restores it.
+func makeRaw(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, errutil.WithFramef("%w: stdin is not a terminal", ErrAttachUnsupported)
+	}
+
+	state := *termios
+
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP |
+		unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, termios); err != nil {
+		return nil, errutil.New("unix.IoctlSetTermios", err)
+	}
+
+	return func() {
+		unix.IoctlSetTermios(fd, ioctlWriteTermios, &state) //nolint:errcheck // best effort
+	}, nil
+}
+
+// setSize sets the window size of the PTY.
+func setSize(master *os.File, rows, cols uint16) error {
+	if err := pty.Setsize(master, &pty.Winsize{Rows: rows, Cols: cols}); err != nil && !errors.Is(err, os.ErrClosed) {
+		return errutil.New("pty.Setsize", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package proc_test
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/proc"
+	"github.com/ricochhet/gpm/internal/proctest"
+)
+
+func TestAttachAcrossRestarts(t *testing.T) {
+	flags := proctest.Flags()
+	flags.RestartOnError = true
+
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "a", Cmd: proctest.Cmd(proctest.Exit, "1"), Flags: config.Flags{Pty: true}},
+	}}, flags)
+
+	h.Start("a")
+	h.WaitEvent("a", "start", 1)
+
+	conn, err := proc.Dial(fmt.Sprintf("127.0.0.1:%d", h.Port), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintf(conn, "GPM/ATTACH a\n"); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(proctest.Timeout)); err != nil {
+		t.Fatalf("deadline: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+
+	if line, err := r.ReadString('\n'); err != nil || line != "OK\n" {
+		t.Fatalf("got %q (%v), wanted OK", line, err)
+	}
+
+	// The client stays attached while the task restarts, receiving the output of
+	// every run.
+	for runs := 0; runs < 2; {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("got %v after %d runs, wanted the output of the next run", err, runs)
+		}
+
+		if strings.HasPrefix(line, "exiting with 1") {
+			runs++
+		}
+	}
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package proc
+
+import (
+	"os"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+// Attach connects the local terminal to the PTY of the task (posix only).
+func Attach(_ string, _ uint, _ string) error {
+	return errutil.WithFrame(ErrAttachUnsupported)
+}
+
+// setSize sets the window size of the PTY (posix only).
+func setSize(_ *os.File, _, _ uint16) error {
+	return nil
+}
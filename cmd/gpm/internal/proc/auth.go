@@ -0,0 +1,120 @@
+package proc
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+)
+
+// authPreamble authenticates a control connection, followed by the token and a
+// newline. The server answers 'OK', or 'ERR' and closes the connection. Servers
+// without a token accept any connection, but refuse remote attach and agents. The
+// token is sent in cleartext, so it only protects connections on trusted networks,
+// or tunneled ones, e.g. over SSH.
+const authPreamble = "GPM/AUTH "
+
+// dialTimeout is how long to wait for an RPC server to accept a connection.
+const dialTimeout = 5 * time.Second
+
+var (
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrTokenRequired = errors.New("a token is required, set -token or GPM_RPC_TOKEN")
+)
+
+// Dial connects to the RPC server, authenticating with the token, or GPM_RPC_TOKEN,
+// if set.
+func Dial(server, token string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", server, dialTimeout)
+	if err != nil {
+		return nil, errutil.New("net.DialTimeout", err)
+	}
+
+	token = config.RPCToken(token)
+	if token == "" {
+		return conn, nil
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s%s\n", authPreamble, token); err != nil {
+		conn.Close()
+		return nil, errutil.WithFrame(err)
+	}
+
+	line, err := readLine(conn)
+	if err != nil {
+		conn.Close()
+		return nil, errutil.WithFrame(err)
+	}
+
+	if line != "OK" {
+		conn.Close()
+		return nil, errutil.WithFramef("%w: %s", ErrUnauthorized, server)
+	}
+
+	return conn, nil
+}
+
+// readLine reads a line without its newline. It reads a byte at a time so nothing past
+// the line is consumed from the connection.
+func readLine(r io.Reader) (string, error) {
+	var (
+		sb strings.Builder
+		b  = make([]byte, 1)
+	)
+
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", errutil.New("io.ReadFull", err)
+		}
+
+		if b[0] == '\n' {
+			return strings.TrimSuffix(sb.String(), "\r"), nil
+		}
+
+		sb.WriteByte(b[0])
+	}
+}
+
+// authenticate returns true if the connection may be served. If the server has a
+// token, the connection must start with the auth preamble and the same token.
+func (ctx *Context) authenticate(conn net.Conn, r *bufio.Reader) bool {
+	token := config.RPCToken(ctx.Flags.Token)
+
+	if b, err := r.Peek(len(authPreamble)); err != nil || string(b) != authPreamble {
+		return token == ""
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	got := strings.TrimSpace(strings.TrimPrefix(line, authPreamble))
+	if token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		fmt.Fprintf(conn, "ERR %s\n", ErrUnauthorized)
+		return false
+	}
+
+	_, err = io.WriteString(conn, "OK\n")
+
+	return err == nil
+}
+
+// isLoopback returns true if the address is a loopback address.
+func isLoopback(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
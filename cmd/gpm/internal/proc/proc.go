@@ -2,10 +2,12 @@ package proc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,6 +19,7 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/check"
 	"github.com/ricochhet/gpm/internal/custom"
 	"github.com/ricochhet/pkg/errutil"
 	"github.com/ricochhet/pkg/fsutil"
@@ -30,6 +33,10 @@ type Context struct {
 	SharedProc        *config.ProcManager
 	StoredProc        *config.ProcManager
 	Builtins          *custom.Builtins
+	Terminals         *Terminals
+	States            *States
+	Agents            *Agents
+	Groups            config.Groups
 	MaxProcNameLength int
 }
 
@@ -95,15 +102,33 @@ func (ctx *Context) Start(rpcCtx context.Context, sig <-chan os.Signal, cfg *con
 		return errors.New("no task specified")
 	}
 
-	tmp := make([]*config.ProcInfo, 0, len(cfg.Args[1:]))
+	if cfg.Controller != "" && config.RPCToken(cfg.Token) == "" {
+		return errutil.WithFramef("%w: -controller %s", ErrTokenRequired, cfg.Controller)
+	}
+
+	names, flags, err := ctx.Groups.Expand(cfg.Args[1:])
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	if flags != nil {
+		cfg = maputil.Merge(cfg, flags, "json", true)
+		ctx.Flags = cfg
+	}
+
+	tmp := make([]*config.ProcInfo, 0, len(names))
 	ctx.MaxProcNameLength = 0
 
-	for _, v := range cfg.Args[1:] {
+	for _, v := range names {
 		proc := ctx.FindProc(v)
 		if proc == nil {
 			return errors.New("unknown proc: " + v)
 		}
 
+		if flags != nil {
+			proc.ApplyFlags(flags)
+		}
+
 		tmp = append(tmp, proc)
 
 		if len(v) > ctx.MaxProcNameLength {
@@ -137,6 +162,10 @@ func (ctx *Context) Start(rpcCtx context.Context, sig <-chan os.Signal, cfg *con
 		}
 	}
 
+	if err := ctx.adoptProcs(); err != nil {
+		logutil.Errorf(os.Stderr, "Failed to re-adopt processes: %v\n", err)
+	}
+
 	rpcChan := make(chan *RPCMessage, 10)
 
 	if cfg.StartRPCServer {
@@ -147,6 +176,14 @@ func (ctx *Context) Start(rpcCtx context.Context, sig <-chan os.Signal, cfg *con
 		}()
 	}
 
+	if cfg.Controller != "" {
+		if cfg.StartRPCServer {
+			go ctx.registerAgent(rpcCtx, cfg)
+		} else {
+			logutil.Warnf(os.Stderr, "Not registering with controller %s: the RPC server is disabled\n", cfg.Controller)
+		}
+	}
+
 	//nolint:contextcheck // wontfix
 	return ctx.StartProcs(sig, rpcChan, cfg.ExitOnError)
 }
@@ -159,110 +196,258 @@ func (ctx *Context) SpawnProc(name string, errCh chan<- error) {
 	}
 
 	logger := logutil.CreateLogger(name, proc.ColorIndex)
+	logger.SetOutput(proc.Output)
+
 	cs := slices.Concat(cmdStart, proc.Cmdline)
 
+	if err := ctx.waitFor(logger, proc); err != nil {
+		select {
+		case errCh <- err:
+		default:
+		}
+
+		logger.Eventf(logutil.LevelError, "notStarted", logutil.Fields{"error": err.Error()},
+			"Not starting %s: %v\n", name, err)
+		proc.Readiness.Exit(false)
+
+		return
+	}
+
 	if ok, err := ctx.Builtins.Start(logger, cs[2], *ctx.Flags); ok ||
 		err != nil {
 		ctx.SpawnProcs(logger, proc.Steps, errCh)
 
+		// Builtins run to completion, and are ready once they succeeded.
+		proc.Readiness.Start()
+
+		if err == nil {
+			proc.Readiness.Set(config.Ready)
+		}
+
+		proc.Readiness.Exit(err == nil)
+
 		errCh <- err
 
 		return
 	}
 
-	for {
-		cmd := exec.CommandContext(context.Background(), cs[0], cs[1:]...)
-		cmd.Dir = proc.Dir
-		cmd.Stdin = nil
+	ready := newReadiness(logger, proc, errCh)
 
-		if ctx.Flags.InheritStdin {
-			cmd.Stdin = os.Stdin
-		}
+	// Attached clients stay attached while the task restarts.
+	defer ctx.Terminals.remove(name)
 
-		if proc.Fork {
-			cmd.Stdout = nil
-			cmd.Stderr = nil
-			cmd.SysProcAttr = forkProcAttrs
-		} else {
-			cmd.Stdout = logger
-			cmd.Stderr = logger
-			cmd.SysProcAttr = procAttrs
-		}
-
-		if proc.Silent {
-			cmd.Stdout = io.Discard
-			cmd.Stderr = io.Discard
+	for {
+		started, err := ctx.runProc(logger, proc, ready, cs, errCh)
+		if !started {
+			proc.Readiness.Exit(false)
+			return
 		}
 
-		var err error
+		proc.Cond.Broadcast()
 
-		// StartPTY sets cmd.Std to the logger, we can optionally set the logger to nil.
-		cleanup, err := ctx.startPTY(logger, cmd)
-		if err != nil {
+		if err != nil && !proc.StoppedBySupervisor {
 			select {
 			case errCh <- err:
 			default:
 			}
-
-			logutil.Infof(logger, "Failed to open pty for %s: %s\n", name, err)
 		}
-		defer cleanup()
 
-		if proc.SetPort {
-			cmd.Env = append(os.Environ(), fmt.Sprintf("PORT=%d", proc.Port))
-			logutil.Infof(logger, "Starting %s on port %d\n", name, proc.Port)
-		}
+		proc.WaitErr = err
+		proc.SetCmd(nil)
+
+		logger.Eventf(logutil.LevelInfo, "exit", exitFields(err), "Terminating %s\n", name)
+		ctx.SpawnProcs(logger, proc.Steps, errCh)
 
-		for key, value := range proc.Env {
-			cmd.Env = append(cmd.Env, fsutil.CombineEnviron(key, value))
-			logutil.Debugf(logger, "added envar: %s=%s\n", key, value)
+		if proc.StoppedBySupervisor || !proc.RestartOnError || err == nil {
+			// Forked processes are still running.
+			if !proc.Fork {
+				proc.Readiness.Exit(err == nil)
+			}
+
+			break
 		}
 
-		logutil.Debugf(logger, "cmd: %s\n", cmd.String())
+		logger.Eventf(logutil.LevelInfo, "restart", nil, "Restarting %s\n", name)
+	}
+}
 
-		if err := cmd.Start(); err != nil {
+// runProc runs the command of the proc once, and returns the error it exited with.
+// Forked processes are not waited for. Any files opened for the command are closed
+// before it returns. If the command could not be started, false is returned.
+func (ctx *Context) runProc(
+	logger *logutil.Logger,
+	proc *config.ProcInfo,
+	ready *readiness,
+	cs []string,
+	errCh chan<- error,
+) (bool, error) {
+	name := proc.Name
+
+	cmd := exec.CommandContext(context.Background(), cs[0], cs[1:]...)
+	cmd.Dir = proc.Dir
+	cmd.Stdin = nil
+
+	if proc.InheritStdin {
+		cmd.Stdin = os.Stdin
+	}
+
+	if proc.Fork {
+		cmd.Stdout = nil
+		cmd.Stderr = nil
+		cmd.SysProcAttr = forkProcAttrs
+	} else {
+		closeStreams, err := setStreams(logger, proc, cmd)
+		if err != nil {
 			select {
 			case errCh <- err:
 			default:
 			}
 
-			logutil.Infof(logger, "Failed to start %s: %s\n", name, err)
+			logger.Eventf(logutil.LevelError, "start", logutil.Fields{"error": err.Error()},
+				"Failed to redirect output of %s: %s\n", name, err)
 
-			return
+			return false, err
 		}
+		defer closeStreams()
 
-		proc.Cmd = cmd
-		proc.StoppedBySupervisor = false
+		cmd.SysProcAttr = procAttrs
+	}
+
+	// StartPTY sets cmd.Std to the pty of tasks that use one.
+	started, cleanup, err := ctx.startPTY(logger, proc, cmd)
+	if err != nil {
+		select {
+		case errCh <- err:
+		default:
+		}
+
+		logger.Eventf(logutil.LevelInfo, "pty", logutil.Fields{"error": err.Error()},
+			"Failed to open pty for %s: %s\n", name, err)
+	}
+	defer cleanup()
+
+	if proc.SetPort {
+		cmd.Env = append(os.Environ(), proc.PortEnv()...)
+		logger.Eventf(logutil.LevelInfo, "start", logutil.Fields{"port": proc.Port},
+			"Starting %s on port %d\n", name, proc.Port)
+	}
 
-		if !proc.Fork {
-			proc.Mu.Unlock()
+	for key, value := range proc.Env {
+		cmd.Env = append(cmd.Env, fsutil.CombineEnviron(key, value))
+		logger.Eventf(logutil.LevelDebug, "env", logutil.Fields{"key": key}, "added envar: %s=%s\n", key, value)
+	}
+
+	logger.Eventf(logutil.LevelDebug, "cmd", nil, "cmd: %s\n", cmd.String())
+
+	proc.Readiness.Start()
 
-			err = cmd.Wait()
+	if err := cmd.Start(); err != nil {
+		proc.Readiness.Stop()
 
-			proc.Mu.Lock()
+		select {
+		case errCh <- err:
+		default:
 		}
 
-		proc.Cond.Broadcast()
+		logger.Eventf(logutil.LevelError, "start", logutil.Fields{"error": err.Error()},
+			"Failed to start %s: %s\n", name, err)
 
-		if err != nil && !proc.StoppedBySupervisor {
-			select {
-			case errCh <- err:
-			default:
+		return false, err
+	}
+
+	started()
+	proc.SetCmd(cmd)
+	proc.StoppedBySupervisor = false
+
+	logger.SetPid(cmd.Process.Pid)
+	ctx.record(proc, cmd.Process.Pid)
+	ready.started()
+
+	// Forked processes outlive the supervisor, they stay recorded to be re-adopted.
+	if !proc.Fork {
+		proc.Mu.Unlock()
+
+		err = ready.exited(cmd.Wait())
+
+		proc.Mu.Lock()
+
+		ctx.forget(name, cmd.Process.Pid)
+		proc.Readiness.Stop()
+	}
+
+	return true, err
+}
+
+// setStreams sets the output streams of the command to the logger, or redirects them
+// as configured by the proc. The returned function closes any opened files.
+func setStreams(logger *logutil.Logger, proc *config.ProcInfo, cmd *exec.Cmd) (func(), error) {
+	stdout, stderr := proc.Stdout, proc.Stderr
+	if proc.Silent {
+		stdout, stderr = config.Discard, config.Discard
+	}
+
+	var files []*os.File
+
+	closeFiles := func() {
+		for _, f := range files {
+			if err := f.Close(); err != nil {
+				logutil.Errorf(os.Stderr, "f.Close: %v\n", err)
 			}
 		}
+	}
 
-		proc.WaitErr = err
-		proc.Cmd = nil
+	// Redirected output is still matched against the readiness patterns of the proc.
+	redirect := func(target string, w *logutil.Logger) (io.Writer, error) {
+		switch target {
+		case "":
+			return w, nil
+		case config.Discard:
+			return w.Matcher(), nil
+		}
 
-		logutil.Infof(logger, "Terminating %s\n", name)
-		ctx.SpawnProcs(logger, proc.Steps, errCh)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, errutil.New("os.MkdirAll", err)
+		}
 
-		if proc.StoppedBySupervisor || !proc.RestartOnError || err == nil {
-			break
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, errutil.New("os.OpenFile", err)
 		}
 
-		logutil.Infof(logger, "Restarting %s\n", name)
+		files = append(files, f)
+
+		return io.MultiWriter(f, w.Matcher()), nil
+	}
+
+	var err error
+
+	if cmd.Stdout, err = redirect(stdout, logger); err != nil {
+		closeFiles()
+		return nil, errutil.WithFrame(err)
+	}
+
+	if cmd.Stderr, err = redirect(stderr, logger.Stderr()); err != nil {
+		closeFiles()
+		return nil, errutil.WithFrame(err)
+	}
+
+	return closeFiles, nil
+}
+
+// exitFields returns the event fields of a process that exited with err.
+func exitFields(err error) logutil.Fields {
+	fields := logutil.Fields{"code": 0}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		fields["code"] = exitErr.ExitCode()
+	}
+
+	if err != nil {
+		fields["error"] = err.Error()
 	}
+
+	return fields
 }
 
 // SpawnProcs starts the specified procs, and returns any error from running it.
@@ -303,7 +488,15 @@ func (ctx *Context) StartProc(name string, wg *sync.WaitGroup, errCh chan<- erro
 	proc.Mu.Lock()
 
 	if proc.Cmd != nil {
+		// Wait on a process that is already running, e.g. one that was adopted.
+		if wg != nil && !proc.Fork {
+			wg.Add(1)
+
+			go waitProc(proc, proc.Cmd, wg)
+		}
+
 		proc.Mu.Unlock()
+
 		return nil
 	}
 
@@ -324,6 +517,18 @@ func (ctx *Context) StartProc(name string, wg *sync.WaitGroup, errCh chan<- erro
 	return nil
 }
 
+// waitProc waits until cmd is no longer the running process of the proc.
+func waitProc(proc *config.ProcInfo, cmd *exec.Cmd, wg *sync.WaitGroup) {
+	proc.Mu.Lock()
+
+	for proc.Cmd == cmd {
+		proc.Cond.Wait()
+	}
+
+	proc.Mu.Unlock()
+	wg.Done()
+}
+
 // StartProcs starts all procs.
 func (ctx *Context) StartProcs(
 	sc <-chan os.Signal,
@@ -416,6 +621,10 @@ func (ctx *Context) StopProcs(sig os.Signal) error {
 	}
 
 	for _, proc := range ctx.SharedProc.All() {
+		if proc.Fork {
+			continue // Forked processes outlive the supervisor.
+		}
+
 		stopErr := ctx.StopProc(proc.Name, sig)
 		if stopErr != nil {
 			err = stopErr
@@ -470,8 +679,38 @@ func (ctx *Context) StopProc(name string, signal os.Signal) error {
 	return errutil.WithFrame(err)
 }
 
-// command: check. show Taskfile entries.
-func (ctx *Context) Check() error {
+// command: check. validate and lint the Taskfile, then show Taskfile entries.
+func (ctx *Context) Check(linter *check.Linter) error {
+	report, err := linter.Run()
+	if err != nil {
+		return errutil.New("linter.Run", err)
+	}
+
+	if ctx.Flags.JSON {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errutil.New("json.MarshalIndent", err)
+		}
+
+		fmt.Fprintf(os.Stdout, "%s\n", b)
+	} else {
+		for _, d := range report.Diagnostics {
+			if d.Severity == check.SeverityError {
+				logutil.Errorf(os.Stdout, "%s\n", d)
+			} else {
+				logutil.Warnf(os.Stdout, "%s\n", d)
+			}
+		}
+	}
+
+	if report.HasErrors() {
+		return errors.New("invalid taskfile")
+	}
+
+	if ctx.Flags.JSON {
+		return nil
+	}
+
 	ctx.Mu.Lock()
 	defer ctx.Mu.Unlock()
 
@@ -487,6 +726,22 @@ func (ctx *Context) Check() error {
 	sort.Strings(keys)
 	logutil.Infof(os.Stdout, "Valid taskfile detected (%s)\n", strings.Join(keys, ", "))
 
+	if ctx.Flags.Verbose {
+		for _, s := range report.Skipped {
+			logutil.Infof(os.Stdout, "Skipped %s: %s\n", s.Task, s.Reason)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(report.Groups)) {
+		logutil.Infof(
+			os.Stdout,
+			"Group %s%s: %s\n",
+			config.GroupPrefix,
+			name,
+			strings.Join(report.Groups[name], ", "),
+		)
+	}
+
 	return nil
 }
 
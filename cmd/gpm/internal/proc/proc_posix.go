@@ -9,7 +9,9 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/creack/pty"
 	"github.com/ricochhet/gpm/config"
@@ -24,6 +26,10 @@ const (
 	sighup  = unix.SIGHUP
 )
 
+// ptyDrainTimeout is how long to wait for the remaining output of an exited process on
+// a PTY.
+const ptyDrainTimeout = time.Second
+
 var (
 	cmdStart      = []string{"/bin/sh", "-c"}
 	procAttrs     = &unix.SysProcAttr{Setpgid: true}
@@ -39,8 +45,11 @@ func terminateProc(proc *config.ProcInfo, signal os.Signal) error {
 		return nil
 	}
 
+	// The process exited, and is being waited on.
 	pgid, err := unix.Getpgid(p.Pid)
-	if err != nil {
+	if errors.Is(err, unix.ESRCH) {
+		return nil
+	} else if err != nil {
 		return errutil.New("unix.Getpgid", err)
 	}
 
@@ -58,6 +67,16 @@ func terminateProc(proc *config.ProcInfo, signal os.Signal) error {
 	return target.Signal(signal)
 }
 
+// getpgid returns the process group of the pid, or the pid if it has exited.
+func getpgid(pid int) int {
+	pgid, err := unix.Getpgid(pid)
+	if err != nil {
+		return pid
+	}
+
+	return pgid
+}
+
 // killProc kills the proc with pid, as well as its children.
 func killProc(process *os.Process) error {
 	return unix.Kill(-1*process.Pid, unix.SIGKILL)
@@ -73,38 +92,70 @@ func NotifyCh() (<-chan os.Signal, func()) {
 	}
 }
 
-// startPTY starts a PTY (posix only).
-func (ctx *Context) startPTY(logger *logutil.Logger, cmd *exec.Cmd) (func(), error) {
-	if ctx.Flags.Pty {
-		p, t, err := pty.Open()
-		if err != nil {
-			return nil, errutil.New("pty.Open", err)
-		}
+// startPTY starts a PTY (posix only). The PTY becomes the controlling terminal of the
+// process, and is registered so it can be attached to with 'gpm run attach'. The
+// returned started function closes the PTY slave in the supervisor once the process
+// started, and cleanup closes the PTY once the process exited.
+func (ctx *Context) startPTY(
+	logger *logutil.Logger,
+	proc *config.ProcInfo,
+	cmd *exec.Cmd,
+) (func(), func(), error) {
+	noop := func() {}
+
+	if !proc.Pty {
+		return noop, noop, nil
+	}
+
+	p, t, err := pty.Open()
+	if err != nil {
+		return noop, noop, errutil.New("pty.Open", err)
+	}
 
-		cmd.Stdout = t
-		cmd.Stderr = t
+	cmd.Stdin = t
+	cmd.Stdout = t
+	cmd.Stderr = t
+	cmd.SysProcAttr = &unix.SysProcAttr{Setsid: true, Setctty: true}
 
-		go func() {
-			if _, err := io.Copy(logger, p); err != nil && !errors.Is(err, io.EOF) {
-				logutil.Errorf(os.Stderr, "io.Copy: %v", err)
-			}
-		}()
+	term := ctx.Terminals.add(proc.Name, p, logger)
+	copied := make(chan struct{})
 
-		cleanup := func() {
-			if err := p.Close(); err != nil {
-				logutil.Errorf(os.Stderr, "p.Close: %v", err)
-			}
+	// The copy ends once every copy of the slave is closed: ours, the process', and
+	// those of any children it left behind.
+	go func() {
+		defer close(copied)
+
+		if _, err := io.Copy(term, p); err != nil && !errors.Is(err, io.EOF) &&
+			!errors.Is(err, syscall.EIO) && !errors.Is(err, os.ErrClosed) {
+			logutil.Errorf(os.Stderr, "io.Copy: %v\n", err)
+		}
+	}()
+
+	var once sync.Once
 
+	closeSlave := func() {
+		once.Do(func() {
 			if err := t.Close(); err != nil {
-				logutil.Errorf(os.Stderr, "t.Close: %v", err)
+				logutil.Errorf(os.Stderr, "t.Close: %v\n", err)
 			}
+		})
+	}
+
+	cleanup := func() {
+		closeSlave()
+
+		// Print the remaining output, unless children of the process still hold the PTY.
+		select {
+		case <-copied:
+		case <-time.After(ptyDrainTimeout):
 		}
 
-		return cleanup, nil
-	}
+		term.release(p)
 
-	cmd.Stdout = logger
-	cmd.Stderr = logger
+		if err := p.Close(); err != nil {
+			logutil.Errorf(os.Stderr, "p.Close: %v\n", err)
+		}
+	}
 
-	return func() {}, nil
+	return closeSlave, cleanup, nil
 }
@@ -0,0 +1,226 @@
+package proc_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/proctest"
+)
+
+func TestMain(m *testing.M) {
+	proctest.Main(m)
+}
+
+func TestExit(t *testing.T) {
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "a", Cmd: proctest.Cmd(proctest.Exit, "0")},
+	}}, proctest.Flags())
+
+	h.Start("a")
+
+	if err := h.Wait(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	h.AssertEvents("a", "start", "exit")
+
+	if code := h.WaitEvent("a", "exit", 1).Fields["code"]; code != float64(0) {
+		t.Errorf("got exit code %v, wanted 0", code)
+	}
+
+	if lines := h.Lines("a", "stdout"); len(lines) != 1 || lines[0] != "exiting with 0" {
+		t.Errorf("got stdout %q", lines)
+	}
+}
+
+func TestExitOnError(t *testing.T) {
+	flags := proctest.Flags()
+	flags.ExitOnError = true
+
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "a", Cmd: proctest.Cmd(proctest.Exit, "3")},
+		{Name: "b", Cmd: proctest.Cmd(proctest.Ready, "up")},
+	}}, flags)
+
+	h.Start("a", "b")
+
+	if err := h.Wait(); err == nil {
+		t.Fatal("got no error, wanted the exit status of a")
+	}
+
+	if code := h.WaitEvent("a", "exit", 1).Fields["code"]; code != float64(3) {
+		t.Errorf("got exit code %v, wanted 3", code)
+	}
+
+	h.WaitEvent("b", "exit", 1)
+}
+
+func TestRestartOnError(t *testing.T) {
+	flags := proctest.Flags()
+	flags.RestartOnError = true
+
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "a", Cmd: proctest.Cmd(proctest.Crash)},
+	}}, flags)
+
+	h.Start("a")
+	h.WaitEvent("a", "start", 3)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	want := []string{"start", "exit", "restart", "start", "exit", "restart", "start"}
+	if got := h.Events("a"); len(got) < len(want) || !slices.Equal(got[:len(want)], want) {
+		t.Errorf("got events %v, wanted them to start with %v", got, want)
+	}
+}
+
+func TestGroupRestartOnError(t *testing.T) {
+	h := proctest.New(t, config.Taskfile{
+		Tasks:  []config.Task{{Name: "a", Cmd: proctest.Cmd(proctest.Crash)}},
+		Groups: config.Groups{{Name: "dev", Tasks: []string{"a"}, Flags: config.Flags{RestartOnError: true}}},
+	}, proctest.Flags())
+
+	h.Start("@dev")
+	h.WaitEvent("a", "restart", 1)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+}
+
+func TestReadyWhen(t *testing.T) {
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "db", Cmd: proctest.Cmd(proctest.Ready, "listening", "200ms"), ReadyWhen: []string{"^listening$"}},
+		{Name: "app", Cmd: proctest.Cmd(proctest.Ready, "up"), ReadyWhen: []string{"^up$"}, WaitFor: []string{"db"}},
+	}}, proctest.Flags())
+
+	h.Start("db", "app")
+	h.WaitEvent("app", "ready", 1)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	h.AssertEvents("db", "start", "ready", "exit")
+	h.AssertEvents("app", "wait", "start", "ready", "exit")
+
+	// The ready event is logged asynchronously, the line that made db ready is not.
+	ready, start := -1, -1
+
+	for i, e := range h.Entries() {
+		switch {
+		case e.Task == "db" && e.Stream == "stdout" && e.Message == "listening":
+			ready = i
+		case e.Task == "app" && e.Event == "start":
+			start = i
+		}
+	}
+
+	if ready < 0 || start < ready {
+		t.Errorf("app started before db was ready")
+	}
+}
+
+func TestWaitForExited(t *testing.T) {
+	// The setup task exits before app waits for it, once db is ready.
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "setup", Cmd: proctest.Cmd(proctest.Exit, "0")},
+		{Name: "db", Cmd: proctest.Cmd(proctest.Ready, "listening", "200ms"), ReadyWhen: []string{"^listening$"}},
+		{Name: "app", Cmd: proctest.Cmd(proctest.Ready, "up"), ReadyWhen: []string{"^up$"}, WaitFor: []string{"db", "setup"}},
+	}}, proctest.Flags())
+
+	h.Start("setup", "db", "app")
+	h.WaitEvent("app", "ready", 1)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	h.AssertEvents("setup", "start", "exit")
+}
+
+func TestWaitForFailed(t *testing.T) {
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "setup", Cmd: proctest.Cmd(proctest.Exit, "0"), ReadyWhen: []string{"^done$"}},
+		{Name: "app", Cmd: proctest.Cmd(proctest.Ready, "up"), WaitFor: []string{"setup"}},
+	}}, proctest.Flags())
+
+	h.Start("setup", "app")
+
+	if err := h.Wait(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	h.AssertEvents("app", "wait", "notStarted")
+}
+
+func TestReadyWhenRedirected(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "db.log")
+
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "db", Cmd: proctest.Cmd(proctest.Ready, "listening"), ReadyWhen: []string{"^listening$"}, Stdout: log},
+		{Name: "app", Cmd: proctest.Cmd(proctest.Ready, "up"), ReadyWhen: []string{"^up$"}, Stdout: config.Discard},
+	}}, proctest.Flags())
+
+	h.Start("db", "app")
+	h.WaitEvent("db", "ready", 1)
+	h.WaitEvent("app", "ready", 1)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	if lines := h.Lines("db", "stdout"); len(lines) != 0 {
+		t.Errorf("got stdout %q, wanted it redirected", lines)
+	}
+
+	if b, err := os.ReadFile(log); err != nil || string(b) != "listening\n" {
+		t.Errorf("got %q (%v), wanted the redirected output", b, err)
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	flags := proctest.Flags()
+	flags.ExitOnError = true
+
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{
+			Name:         "a",
+			Cmd:          proctest.Cmd(proctest.Ready, "starting"),
+			ReadyWhen:    []string{"^listening$"},
+			ReadyTimeout: "100ms",
+		},
+	}}, flags)
+
+	h.Start("a")
+
+	if err := h.Wait(); err == nil {
+		t.Fatal("got no error, wanted a not ready error")
+	}
+
+	h.AssertEvents("a", "start", "notReady", "exit")
+}
+
+func TestStopIgnoringInterrupt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the stop timeout")
+	}
+
+	h := proctest.New(t, config.Taskfile{Tasks: []config.Task{
+		{Name: "a", Cmd: proctest.Cmd(proctest.IgnoreSigint), ReadyWhen: []string{"^ignoring"}},
+	}}, proctest.Flags())
+
+	h.Start("a")
+	h.WaitEvent("a", "ready", 1)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	h.AssertEvents("a", "start", "ready", "exit")
+}
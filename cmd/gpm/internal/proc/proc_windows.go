@@ -76,6 +76,11 @@ func terminateProc(proc *config.ProcInfo, _ os.Signal) error {
 	return nil
 }
 
+// getpgid returns the pid, the process is created in a new process group.
+func getpgid(pid int) int {
+	return pid
+}
+
 // killProc kills the proc with pid, as well as its children.
 func killProc(process *os.Process) error {
 	return process.Kill()
@@ -92,9 +97,10 @@ func NotifyCh() (<-chan os.Signal, func()) {
 }
 
 // startPTY starts a PTY (posix only).
-func (ctx *Context) startPTY(logger *logutil.Logger, cmd *exec.Cmd) (func(), error) {
-	cmd.Stdout = logger
-	cmd.Stderr = logger
-
-	return func() {}, nil
+func (ctx *Context) startPTY(
+	_ *logutil.Logger,
+	_ *config.ProcInfo,
+	_ *exec.Cmd,
+) (func(), func(), error) {
+	return func() {}, func() {}, nil
 }
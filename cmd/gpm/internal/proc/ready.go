@@ -0,0 +1,161 @@
+package proc
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+var (
+	ErrNotReady      = errors.New("task not ready")
+	ErrErrorPattern  = errors.New("output matched errorWhen")
+	ErrStderrPattern = errors.New("stderr matched errorWhenStderr")
+	ErrUnknownTask   = errors.New("unknown task")
+)
+
+// readiness matches the output of a proc against its readiness patterns.
+type readiness struct {
+	proc   *config.ProcInfo
+	logger *logutil.Logger
+	errCh  chan<- error
+
+	mu      sync.Mutex
+	errLine string // The last line that matched errorWhen.
+	timer   *time.Timer
+}
+
+// newReadiness registers the readiness patterns of the proc on the logger.
+func newReadiness(logger *logutil.Logger, proc *config.ProcInfo, errCh chan<- error) *readiness {
+	r := &readiness{proc: proc, logger: logger, errCh: errCh}
+
+	// Matchers must not write to the logger they are called from.
+	logger.Match(proc.ReadyWhen, func(string) {
+		if proc.Readiness.Set(config.Ready) {
+			go logger.Eventf(logutil.LevelInfo, "ready", nil, "%s is ready\n", proc.Name)
+		}
+	})
+	logger.Match(proc.ErrorWhen, func(line string) {
+		r.mu.Lock()
+		r.errLine = line
+		r.mu.Unlock()
+
+		proc.Readiness.Set(config.NotReady)
+	})
+	logger.MatchStream("stderr", proc.ErrorWhenStderr, func(line string) {
+		r.mu.Lock()
+		r.errLine = line
+		r.mu.Unlock()
+
+		proc.Readiness.Set(config.NotReady)
+
+		select {
+		case errCh <- errutil.WithFramef("%w: %s", ErrStderrPattern, line):
+		default:
+		}
+	})
+
+	return r
+}
+
+// started marks the proc as ready if it has no readyWhen patterns, or is forked, and
+// otherwise starts the ready timeout.
+func (r *readiness) started() {
+	if len(r.proc.ReadyWhen) == 0 || r.proc.Fork {
+		r.proc.Readiness.Set(config.Ready)
+		return
+	}
+
+	if r.proc.ReadyTimeout <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.timer = time.AfterFunc(r.proc.ReadyTimeout, func() {
+		if !r.proc.Readiness.Set(config.NotReady) {
+			return
+		}
+
+		r.logger.Eventf(
+			logutil.LevelError,
+			"notReady",
+			logutil.Fields{"timeout": r.proc.ReadyTimeout.String()},
+			"%s is not ready after %s\n",
+			r.proc.Name,
+			r.proc.ReadyTimeout,
+		)
+
+		select {
+		case r.errCh <- errutil.WithFramef("%w: %s", ErrNotReady, r.proc.Name):
+		default:
+		}
+	})
+}
+
+// exited stops the ready timeout, and returns an error if the output of the process
+// matched errorWhen, even if err is nil.
+func (r *readiness) exited(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	line := r.errLine
+	r.errLine = ""
+
+	if err == nil && line != "" {
+		return errutil.WithFramef("%w: %s", ErrErrorPattern, line)
+	}
+
+	return err
+}
+
+// waitFor waits until every task the proc waits for is ready. Tasks that are not
+// being started, and are not running, are not waited for. The wait for a task with a
+// ready timeout is bounded by it.
+func (ctx *Context) waitFor(logger *logutil.Logger, proc *config.ProcInfo) error {
+	for _, name := range proc.WaitFor {
+		dep := ctx.storedProc(name)
+		if dep == nil {
+			return errutil.WithFramef("%w: %s", ErrUnknownTask, name)
+		}
+
+		if ctx.FindProc(name) == nil && dep.Readiness.State() == "" {
+			logger.Eventf(logutil.LevelWarn, "wait", logutil.Fields{"task": name, "started": false},
+				"%s is not started, not waiting for it\n", name)
+			continue
+		}
+
+		if dep.Readiness.State() != config.Ready {
+			logger.Eventf(logutil.LevelInfo, "wait", logutil.Fields{"task": name}, "Waiting for %s\n", name)
+		}
+
+		if state := dep.Readiness.Wait(dep.ReadyTimeout); state != config.Ready {
+			return errutil.WithFramef("%w: %s", ErrNotReady, name)
+		}
+	}
+
+	return nil
+}
+
+// storedProc finds the proc by name among all procs of the taskfile.
+func (ctx *Context) storedProc(name string) *config.ProcInfo {
+	ctx.Mu.Lock()
+	defer ctx.Mu.Unlock()
+
+	for _, proc := range ctx.StoredProc.All() {
+		if proc.Name == name || slices.Contains(proc.Aliases, name) {
+			return proc
+		}
+	}
+
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/rpc"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 type Gpm struct {
 	rpcChan chan<- *RPCMessage
 	ctx     *Context
+	port    uint // Port the server listens on.
 }
 
 type RPCMessage struct {
@@ -36,13 +40,24 @@ func (r *Gpm) Start(args []string, _ *string) (err error) {
 		}
 	}()
 
+	args, remote := r.ctx.splitTargets(args)
+
+	args, flags, err := r.ctx.Groups.Expand(args)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
 	for _, arg := range args {
+		if proc := r.ctx.FindProc(arg); proc != nil && flags != nil {
+			proc.ApplyFlags(flags)
+		}
+
 		if err = r.ctx.StartProc(arg, nil, nil); err != nil {
-			break
+			return errutil.WithFrame(err)
 		}
 	}
 
-	return errutil.WithFrame(err)
+	return r.ctx.forward("Gpm.Start", remote)
 }
 
 // Stop do stop.
@@ -55,6 +70,12 @@ func (r *Gpm) Stop(args []string, _ *string) (err error) {
 		}
 	}()
 
+	args, remote := r.ctx.splitTargets(args)
+
+	if args, _, err = r.ctx.Groups.Expand(args); err != nil {
+		return errutil.WithFrame(err)
+	}
+
 	errChan := make(chan error, 1)
 	r.rpcChan <- &RPCMessage{
 		Msg:   "stop",
@@ -62,9 +83,11 @@ func (r *Gpm) Stop(args []string, _ *string) (err error) {
 		ErrCh: errChan,
 	}
 
-	err = <-errChan
+	if err = <-errChan; err != nil {
+		return err
+	}
 
-	return err
+	return r.ctx.forward("Gpm.Stop", remote)
 }
 
 // StopAll do stop all.
@@ -96,13 +119,19 @@ func (r *Gpm) Restart(args []string, _ *string) (err error) {
 		}
 	}()
 
+	args, remote := r.ctx.splitTargets(args)
+
+	if args, _, err = r.ctx.Groups.Expand(args); err != nil {
+		return errutil.WithFrame(err)
+	}
+
 	for _, arg := range args {
 		if err = r.ctx.RestartProc(arg); err != nil {
-			break
+			return errutil.WithFrame(err)
 		}
 	}
 
-	return errutil.WithFrame(err)
+	return r.ctx.forward("Gpm.Restart", remote)
 }
 
 // RestartAll do restart all.
@@ -142,8 +171,9 @@ func (r *Gpm) List(_ []string, ret *string) (err error) {
 	return errutil.WithFrame(err)
 }
 
-// Status do status.
-func (r *Gpm) Status(_ []string, ret *string) (err error) {
+// Status do status. Without args, the status of every task and of every agent is
+// returned, otherwise only of the targeted tasks and agents.
+func (r *Gpm) Status(args []string, ret *string) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if e, ok := r.(error); ok {
@@ -152,26 +182,78 @@ func (r *Gpm) Status(_ []string, ret *string) (err error) {
 		}
 	}()
 
-	*ret = ""
+	local, remote := r.ctx.splitTargets(args)
 
-	for _, proc := range r.ctx.SharedProc.All() {
-		if proc.Cmd != nil {
-			*ret += "*" + proc.Name + "\n"
-		} else {
-			*ret += " " + proc.Name + "\n"
-		}
+	*ret = r.ctx.localStatus(local, len(args) == 0)
+
+	agents := slices.Sorted(maps.Keys(remote))
+	if len(args) == 0 {
+		agents = r.ctx.Agents.names()
+	}
+
+	for _, name := range agents {
+		*ret += r.ctx.agentStatus(name, remote[name])
 	}
 
 	return errutil.WithFrame(err)
 }
 
+// LocalStatus returns the status of the tasks, all if there are none, without the
+// status of any agents. Controllers call it on their agents, so a cycle of controllers
+// and agents does not recurse.
+func (r *Gpm) LocalStatus(args []string, ret *string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			}
+		}
+	}()
+
+	*ret = r.ctx.localStatus(args, len(args) == 0)
+
+	return nil
+}
+
+// localStatus returns the status of the named tasks, or of every task if all is true.
+func (ctx *Context) localStatus(names []string, all bool) string {
+	var sb strings.Builder
+
+	for _, proc := range ctx.SharedProc.All() {
+		targeted := slices.ContainsFunc(names, func(name string) bool {
+			return ctx.FindProc(name) == proc
+		})
+		if !all && !targeted {
+			continue
+		}
+
+		line := " " + proc.Name
+		if proc.Running() {
+			line = "*" + proc.Name
+		}
+
+		if state := proc.Readiness.State(); state != "" {
+			line += " (" + string(state) + ")"
+		}
+
+		if ports := proc.PortEnv(); len(ports) != 0 {
+			line += " " + strings.Join(ports, " ")
+		}
+
+		sb.WriteString(line + "\n")
+	}
+
+	return sb.String()
+}
+
 // command: run.
-func Run(cmd string, args []string, serverPort uint) error {
-	client, err := rpc.Dial("tcp", config.DefaultServer(serverPort))
+func Run(cmd string, args []string, serverPort uint, token string) error {
+	conn, err := Dial(config.DefaultServer(serverPort), token)
 	if err != nil {
-		return errutil.New("rpc.Dial", err)
+		return errutil.WithFrame(err)
 	}
 
+	client := rpc.NewClient(conn)
 	defer client.Close()
 
 	var ret string
@@ -195,6 +277,12 @@ func Run(cmd string, args []string, serverPort uint) error {
 		fmt.Print(ret)
 
 		return nil
+	case "attach":
+		if len(args) != 1 {
+			return errors.New("attach requires a single task")
+		}
+
+		return Attach(args[0], serverPort, token)
 	case "status":
 		if err := client.Call("Gpm.Status", args, &ret); err != nil {
 			return errutil.New("client.Call (Gpm.Status)", err)
@@ -217,9 +305,12 @@ func (ctx *Context) StartServer(
 	gm := &Gpm{
 		rpcChan: rpcChan,
 		ctx:     ctx,
+		port:    listenPort,
 	}
-	if err := rpc.Register(gm); err != nil {
-		return errutil.New("rpc.Register", err)
+	// Each server has its own registry, so a process can run several contexts.
+	srv := rpc.NewServer()
+	if err := srv.Register(gm); err != nil {
+		return errutil.New("srv.Register", err)
 	}
 
 	lc := net.ListenConfig{}
@@ -228,6 +319,7 @@ func (ctx *Context) StartServer(
 	if err != nil {
 		return errutil.New("net.Listen", err)
 	}
+	defer server.Close()
 
 	var wg sync.WaitGroup
 
@@ -256,7 +348,7 @@ outer:
 			go func() {
 				defer wg.Done()
 
-				rpc.ServeConn(client)
+				ctx.serveConn(client, srv.ServeConn)
 			}()
 		}
 	}
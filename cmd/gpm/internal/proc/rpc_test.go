@@ -0,0 +1,132 @@
+package proc_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/proctest"
+)
+
+// readyTasks returns tasks that are ready once their helper process runs.
+func readyTasks(names ...string) config.Taskfile {
+	taskfile := config.Taskfile{}
+
+	for _, name := range names {
+		taskfile.Tasks = append(taskfile.Tasks, config.Task{
+			Name:      name,
+			Cmd:       proctest.Cmd(proctest.Ready, "up"),
+			ReadyWhen: []string{"^up$"},
+		})
+	}
+
+	return taskfile
+}
+
+// rpcFlags returns flags of a supervisor that keeps running once its tasks stop.
+func rpcFlags() *config.Flags {
+	flags := proctest.Flags()
+	flags.ExitOnStop = false
+
+	return flags
+}
+
+func TestRPCStartStopRestart(t *testing.T) {
+	h := proctest.New(t, readyTasks("a", "b"), rpcFlags())
+
+	h.Start("a", "b")
+	h.WaitEvent("a", "ready", 1)
+	h.WaitEvent("b", "ready", 1)
+
+	if _, err := h.Call("Stop", "a"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	status, err := h.Call("Status")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+
+	if !strings.Contains(status, " a ") || !strings.Contains(status, "*b ") {
+		t.Errorf("got status %q, wanted a stopped and b running", status)
+	}
+
+	if _, err := h.Call("Start", "a"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	h.WaitEvent("a", "ready", 2)
+
+	if _, err := h.Call("Restart", "b"); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+
+	h.WaitEvent("b", "ready", 2)
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+
+	h.AssertEvents("a", "start", "ready", "exit", "start", "ready", "exit")
+	h.AssertEvents("b", "start", "ready", "exit", "start", "ready", "exit")
+}
+
+func TestRPCUnknownTask(t *testing.T) {
+	h := proctest.New(t, readyTasks("a"), rpcFlags())
+
+	h.Start("a")
+
+	for _, method := range []string{"Start", "Stop", "Restart"} {
+		if _, err := h.Call(method, "nope"); err == nil {
+			t.Errorf("%s: got no error for an unknown task", method)
+		}
+	}
+}
+
+// TestRPCConcurrent starts, stops and restarts tasks from several clients at once.
+// Run with -race.
+func TestRPCConcurrent(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	h := proctest.New(t, readyTasks(names...), rpcFlags())
+
+	h.Start(names...)
+
+	var wg sync.WaitGroup
+
+	for i := range 6 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			name := names[i%len(names)]
+
+			for j := range 5 {
+				method := []string{"Stop", "Start", "Restart", "Status"}[(i+j)%4]
+				if _, err := h.Call(method, name); err != nil {
+					t.Errorf("%s %s: %v", method, name, err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if _, err := h.Call("StopAll"); err != nil {
+		t.Fatalf("stop all: %v", err)
+	}
+
+	status, err := h.Call("Status")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+
+	if strings.Contains(status, "*") {
+		t.Errorf("got status %q, wanted every task stopped", status)
+	}
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("got %v, wanted no error", err)
+	}
+}
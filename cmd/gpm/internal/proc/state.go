@@ -0,0 +1,227 @@
+package proc
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// adoptPollInterval is how often an adopted process is checked for exit.
+const adoptPollInterval = 250 * time.Millisecond
+
+var (
+	ErrAdoptUnsupported = errors.New("re-adopting processes is not supported on this platform")
+	ErrMalformedStat    = errors.New("malformed process stat")
+)
+
+// States records the started processes to the state file of the taskfile.
+type States struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStates returns an empty States, which records nothing until a path is set.
+func NewStates() *States {
+	return &States{}
+}
+
+// SetPath sets the path of the state file.
+func (s *States) SetPath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+}
+
+// update reads the state file and applies fn, writing the state if fn returns true.
+func (s *States) update(fn func(state *config.State) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	state, err := config.ReadState(s.path)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	if !fn(state) {
+		return nil
+	}
+
+	return errutil.WithFrame(state.Write(s.path))
+}
+
+// Running returns the recorded processes that are still running by name, which are
+// re-adopted if their proc runs the same command.
+func (s *States) Running() map[string]config.ProcState {
+	procs := map[string]config.ProcState{}
+
+	err := s.update(func(state *config.State) bool {
+		for name, entry := range state.Procs {
+			if running(entry) {
+				procs[name] = entry
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		logutil.Errorf(os.Stderr, "Failed to read state: %v\n", err)
+	}
+
+	return procs
+}
+
+// record adds the process started for the proc to the state file.
+func (ctx *Context) record(proc *config.ProcInfo, pid int) {
+	start, err := startTime(pid)
+	if err != nil && !errors.Is(err, ErrAdoptUnsupported) {
+		logutil.Debugf(os.Stdout, "failed to read start time of %s: %v\n", proc.Name, err)
+	}
+
+	entry := config.ProcState{
+		Pid:       pid,
+		Pgid:      getpgid(pid),
+		StartTime: start,
+		CmdHash:   proc.CmdHash(),
+	}
+
+	err = ctx.States.update(func(state *config.State) bool {
+		state.Procs[proc.Name] = entry
+		return true
+	})
+	if err != nil {
+		logutil.Errorf(os.Stderr, "Failed to write state: %v\n", err)
+	}
+}
+
+// forget removes the proc from the state file, if it is recorded with pid.
+func (ctx *Context) forget(name string, pid int) {
+	err := ctx.States.update(func(state *config.State) bool {
+		if entry, ok := state.Procs[name]; !ok || entry.Pid != pid {
+			return false
+		}
+
+		delete(state.Procs, name)
+
+		return true
+	})
+	if err != nil {
+		logutil.Errorf(os.Stderr, "Failed to write state: %v\n", err)
+	}
+}
+
+// adoptProcs re-adopts the processes of a previous supervisor that are still running
+// the same command, and removes the entries of processes that are no longer running.
+// Running processes of procs that are not being started are left as they are.
+func (ctx *Context) adoptProcs() error {
+	return ctx.States.update(func(state *config.State) bool {
+		changed := false
+
+		for name, entry := range state.Procs {
+			if !running(entry) {
+				logutil.Debugf(os.Stdout, "removing stale state of %s (pid %d)\n", name, entry.Pid)
+				delete(state.Procs, name)
+
+				changed = true
+
+				continue
+			}
+
+			proc := ctx.FindProc(name)
+			if proc == nil {
+				continue
+			}
+
+			if entry.CmdHash != proc.CmdHash() {
+				logutil.Warnf(
+					os.Stdout,
+					"%s (pid %d) is running a different command, not adopting\n",
+					name,
+					entry.Pid,
+				)
+				delete(state.Procs, name)
+
+				changed = true
+
+				continue
+			}
+
+			ctx.adopt(proc, entry)
+		}
+
+		return changed
+	})
+}
+
+// adopt sets the process of the entry as the running process of the proc, unless the
+// proc is already running.
+func (ctx *Context) adopt(proc *config.ProcInfo, entry config.ProcState) {
+	proc.Mu.Lock()
+	defer proc.Mu.Unlock()
+
+	if proc.Cmd != nil {
+		return
+	}
+
+	p, err := os.FindProcess(entry.Pid)
+	if err != nil {
+		logutil.Debugf(os.Stdout, "failed to find %s (pid %d): %v\n", proc.Name, entry.Pid, err)
+		return
+	}
+
+	cmd := &exec.Cmd{Process: p}
+	proc.SetCmd(cmd)
+	proc.StoppedBySupervisor = false
+	proc.Readiness.Start()
+	proc.Readiness.Set(config.Ready)
+
+	logger := logutil.CreateLogger(proc.Name, proc.ColorIndex)
+	logger.SetPid(entry.Pid)
+	logger.Eventf(logutil.LevelInfo, "adopt", nil, "Adopted %s (pid %d)\n", proc.Name, entry.Pid)
+
+	go ctx.watch(logger, proc, cmd, entry)
+}
+
+// watch waits for an adopted process to exit. It is not a child of the supervisor,
+// so it is polled rather than waited on.
+func (ctx *Context) watch(
+	logger *logutil.Logger,
+	proc *config.ProcInfo,
+	cmd *exec.Cmd,
+	entry config.ProcState,
+) {
+	for running(entry) {
+		time.Sleep(adoptPollInterval)
+	}
+
+	proc.Mu.Lock()
+
+	if proc.Cmd == cmd {
+		proc.SetCmd(nil)
+		proc.Readiness.Exit(false) // The exit status of an adopted process is unknown.
+		proc.Cond.Broadcast()
+	}
+
+	proc.Mu.Unlock()
+
+	ctx.forget(proc.Name, entry.Pid)
+	logger.Eventf(logutil.LevelInfo, "exit", nil, "Terminating %s\n", proc.Name)
+}
+
+// running returns true if the process of the entry is running. A process is only
+// considered the same if its start time matches, as pids are reused.
+func running(entry config.ProcState) bool {
+	start, err := startTime(entry.Pid)
+
+	return err == nil && start == entry.StartTime
+}
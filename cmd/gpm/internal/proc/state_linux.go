@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+// startTime returns the start time of the process in clock ticks since boot, read from
+// /proc/<pid>/stat. Zombie processes are reported as exited.
+func startTime(pid int) (uint64, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, errutil.New("os.ReadFile", err)
+	}
+
+	// The command name may contain spaces and parentheses, fields follow the last ')'.
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 {
+		return 0, errutil.WithFramef("%w: %d", ErrMalformedStat, pid)
+	}
+
+	// Fields after the name start at 'state' (3), the start time is field 22.
+	fields := bytes.Fields(b[i+1:])
+	if len(fields) < 20 {
+		return 0, errutil.WithFramef("%w: %d", ErrMalformedStat, pid)
+	}
+
+	if string(fields[0]) == "Z" || string(fields[0]) == "X" {
+		return 0, os.ErrProcessDone
+	}
+
+	start, err := strconv.ParseUint(string(fields[19]), 10, 64)
+	if err != nil {
+		return 0, errutil.New("strconv.ParseUint", err)
+	}
+
+	return start, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package proc
+
+// startTime is unsupported, so processes of a previous supervisor are never adopted.
+func startTime(_ int) (uint64, error) {
+	return 0, ErrAdoptUnsupported
+}
@@ -0,0 +1,11 @@
+//go:build linux
+// +build linux
+
+package proc
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlReadTermios  = unix.TCGETS
+	ioctlWriteTermios = unix.TCSETS
+)
@@ -0,0 +1,104 @@
+package proctest
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// helperEnv names the helper process the test binary runs as, see Main.
+const helperEnv = "GPM_TEST_HELPER"
+
+// Helper processes, see Cmd.
+const (
+	Exit         = "exit"          // Exits with the code of its argument, 0 by default.
+	Crash        = "crash"         // Kills itself.
+	IgnoreSigint = "ignore-sigint" // Ignores interrupts, running until killed.
+	Ready        = "ready"         // Prints its argument after an optional delay, and runs until interrupted.
+)
+
+// Main runs the helper process the test binary was started as, or the tests otherwise.
+// Test packages that use Cmd must call it from TestMain.
+func Main(m *testing.M) {
+	if name := os.Getenv(helperEnv); name != "" {
+		os.Exit(helper(name, os.Args[1:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+// Cmd returns the task command of a helper process, which re-executes the test binary.
+func Cmd(helper string, args ...string) []string {
+	exe, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	parts := []string{helperEnv + "=" + helper, "exec", quote(exe)}
+	for _, arg := range args {
+		parts = append(parts, quote(arg))
+	}
+
+	return []string{strings.Join(parts, " ")}
+}
+
+// quote quotes s for a posix shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// helper runs the helper process, and returns its exit code.
+func helper(name string, args []string) int {
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+
+		return ""
+	}
+
+	switch name {
+	case Exit:
+		code, _ := strconv.Atoi(arg(0))
+		fmt.Printf("exiting with %d\n", code)
+
+		return code
+	case Crash:
+		fmt.Println("crashing")
+
+		p, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			p.Kill() //nolint:errcheck // exits either way
+		}
+
+		return 1
+	case IgnoreSigint:
+		signal.Ignore(os.Interrupt)
+		fmt.Println("ignoring interrupts")
+
+		for {
+			time.Sleep(time.Hour)
+		}
+	case Ready:
+		sc := make(chan os.Signal, 1)
+		signal.Notify(sc, os.Interrupt, syscall.SIGTERM)
+
+		if delay, err := time.ParseDuration(arg(1)); err == nil {
+			time.Sleep(delay)
+		}
+
+		fmt.Println(arg(0))
+		<-sc
+
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown helper: %s\n", name)
+
+	return 2
+}
@@ -0,0 +1,360 @@
+// Package proctest runs a supervisor from an in-memory Taskfile, and records the
+// events it logs. Tasks run helper processes that re-execute the test binary, see Cmd.
+package proctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/custom"
+	"github.com/ricochhet/gpm/internal/ports"
+	"github.com/ricochhet/gpm/internal/proc"
+	"github.com/ricochhet/pkg/errutil"
+	"github.com/ricochhet/pkg/logutil"
+)
+
+// Timeout is how long the harness waits for the supervisor, or for an event.
+var Timeout = 15 * time.Second
+
+// exclusive is held by a harness for its lifetime, as loggers print to a single writer.
+var exclusive sync.Mutex
+
+// Harness runs a proc.Context, and records the entries printed by its loggers.
+type Harness struct {
+	Ctx  *proc.Context
+	Port uint // Port of the RPC server.
+
+	t      testing.TB
+	sig    chan os.Signal
+	done   chan error
+	err    error
+	ran    bool
+	writer io.Writer // Restored on cleanup.
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	entries []logutil.Entry
+	partial []byte
+}
+
+// Flags returns the flags of a plain 'gpm start', with the RPC server on a free port.
+func Flags() *config.Flags {
+	return &config.Flags{
+		StartRPCServer: true,
+		SetPorts:       true,
+		BasePort:       30000,
+		ExitOnStop:     true,
+	}
+}
+
+// New creates a harness running the tasks of the taskfile. Tasks are read as by
+// 'gpm start', except that includes, builtins and when clauses are not supported.
+// Harnesses run one at a time, and the tests using them must not be parallel.
+func New(t testing.TB, taskfile config.Taskfile, flags *config.Flags) *Harness {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("helper processes require a posix shell")
+	}
+
+	exclusive.Lock()
+
+	h := &Harness{
+		t:    t,
+		sig:  make(chan os.Signal, 1),
+		done: make(chan error, 1),
+	}
+	h.cond = sync.NewCond(&h.mu)
+
+	logutil.LogJSON.Store(true)
+	h.writer = logutil.SetWriter(h)
+	t.Cleanup(h.cleanup)
+
+	if flags.StartRPCServer && flags.Port == 0 {
+		flags.Port = freePort(t)
+	}
+
+	h.Port = flags.Port
+	h.Ctx = &proc.Context{
+		Mu:         &sync.Mutex{},
+		Flags:      flags,
+		SharedProc: config.NewProcManager(),
+		StoredProc: config.NewProcManager(),
+		Builtins:   custom.NewDefaultBuiltins(),
+		Terminals:  proc.NewTerminals(),
+		States:     proc.NewStates(),
+		Agents:     proc.NewAgents(),
+		Groups:     taskfile.Groups,
+	}
+
+	if err := h.read(taskfile); err != nil {
+		t.Fatalf("reading taskfile: %v", err)
+	}
+
+	return h
+}
+
+// read adds the procs of the taskfile, as readTaskfile does.
+func (h *Harness) read(taskfile config.Taskfile) error {
+	allocator, err := ports.Read(filepath.Join(h.t.TempDir(), "ports.cue"), h.Ctx.Flags.BasePort, false)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	for index, task := range taskfile.Tasks {
+		proc, err := config.NewProcInfo(task, taskfile.Env, h.Ctx.Flags, index%len(logutil.Colors))
+		if err != nil {
+			return errutil.New("task %s", err, task.Name)
+		}
+
+		if h.Ctx.Flags.SetPorts {
+			if err := proc.AssignPorts(allocator, index, task.Ports); err != nil {
+				return errutil.WithFrame(err)
+			}
+		}
+
+		h.Ctx.SharedProc.Add(proc)
+	}
+
+	h.Ctx.StoredProc.CopyFrom(h.Ctx.SharedProc)
+
+	return nil
+}
+
+// Start starts the supervisor with the tasks, as 'gpm start' does, and waits for its
+// RPC server, unless the supervisor returns first.
+func (h *Harness) Start(names ...string) {
+	h.t.Helper()
+
+	h.ran = true
+	h.Ctx.Flags.Args = append([]string{"start"}, names...)
+
+	// Start replaces the flags with those merged with any started group.
+	flags := h.Ctx.Flags
+
+	go func() {
+		h.done <- h.Ctx.Start(context.Background(), h.sig, flags)
+	}()
+
+	if !flags.StartRPCServer {
+		return
+	}
+
+	deadline := time.Now().Add(Timeout)
+	for {
+		conn, err := net.Dial("tcp", h.addr())
+		if err == nil {
+			conn.Close()
+			return
+		}
+
+		if time.Now().After(deadline) {
+			h.t.Fatalf("RPC server did not start: %v", err)
+		}
+
+		select {
+		case h.err = <-h.done:
+			h.done = nil
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Stop interrupts the supervisor, and returns the error it returned.
+func (h *Harness) Stop() error {
+	h.t.Helper()
+
+	select {
+	case h.sig <- os.Interrupt:
+	default:
+	}
+
+	return h.Wait()
+}
+
+// Wait waits for the supervisor to return, and returns its error.
+func (h *Harness) Wait() error {
+	h.t.Helper()
+
+	if h.done == nil {
+		return h.err
+	}
+
+	select {
+	case h.err = <-h.done:
+		h.done = nil
+	case <-time.After(Timeout):
+		h.t.Fatalf("supervisor did not return within %s", Timeout)
+	}
+
+	return h.err
+}
+
+// Call calls the RPC method of the supervisor, e.g. 'Status', and returns its result.
+func (h *Harness) Call(method string, args ...string) (string, error) {
+	conn, err := proc.Dial(h.addr(), h.Ctx.Flags.Token)
+	if err != nil {
+		return "", errutil.WithFrame(err)
+	}
+
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	var ret string
+	if err := client.Call("Gpm."+method, args, &ret); err != nil {
+		return "", errutil.New("client.Call (Gpm.%s)", err, method)
+	}
+
+	return ret, nil
+}
+
+// addr returns the address of the RPC server.
+func (h *Harness) addr() string {
+	return fmt.Sprintf("127.0.0.1:%d", h.Port)
+}
+
+// Write implements io.Writer, recording the entries printed by loggers.
+func (h *Harness) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.partial = append(h.partial, p...)
+
+	for {
+		i := bytes.IndexByte(h.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		var entry logutil.Entry
+		if err := json.Unmarshal(h.partial[:i], &entry); err != nil {
+			entry = logutil.Entry{Stream: "invalid", Message: string(h.partial[:i])}
+		}
+
+		h.entries = append(h.entries, entry)
+		h.partial = h.partial[i+1:]
+	}
+
+	h.cond.Broadcast()
+
+	return len(p), nil
+}
+
+// Entries returns the recorded entries, in the order they were printed.
+func (h *Harness) Entries() []logutil.Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return slices.Clone(h.entries)
+}
+
+// Events returns the supervisor events of the task, in the order they were printed.
+func (h *Harness) Events(task string) []string {
+	events := []string{}
+
+	for _, e := range h.Entries() {
+		if e.Task == task && e.Stream == "supervisor" {
+			events = append(events, e.Event)
+		}
+	}
+
+	return events
+}
+
+// Lines returns the lines the task printed to the stream, 'stdout' or 'stderr'.
+func (h *Harness) Lines(task, stream string) []string {
+	lines := []string{}
+
+	for _, e := range h.Entries() {
+		if e.Task == task && e.Stream == stream {
+			lines = append(lines, e.Message)
+		}
+	}
+
+	return lines
+}
+
+// WaitEvent waits until the task has logged the event n times, and returns the last
+// entry of the event.
+func (h *Harness) WaitEvent(task, event string, n int) logutil.Entry {
+	h.t.Helper()
+
+	timedOut := false
+	timer := time.AfterFunc(Timeout, func() {
+		h.mu.Lock()
+		timedOut = true
+		h.mu.Unlock()
+		h.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for {
+		count := 0
+
+		for _, e := range h.entries {
+			if e.Task == task && e.Stream == "supervisor" && e.Event == event {
+				if count++; count == n {
+					return e
+				}
+			}
+		}
+
+		if timedOut {
+			h.t.Fatalf("task %s logged %s %d of %d times within %s", task, event, count, n, Timeout)
+		}
+
+		h.cond.Wait()
+	}
+}
+
+// AssertEvents fails the test unless the task logged exactly the events, in order.
+func (h *Harness) AssertEvents(task string, want ...string) {
+	h.t.Helper()
+
+	if got := h.Events(task); !slices.Equal(got, want) {
+		h.t.Errorf("task %s: got events %v, wanted %v", task, got, want)
+	}
+}
+
+// cleanup stops the supervisor if it is still running, and restores the logger output.
+func (h *Harness) cleanup() {
+	if h.ran && h.done != nil {
+		if err := h.Stop(); err != nil {
+			h.t.Logf("stopping supervisor: %v", err)
+		}
+	}
+
+	logutil.SetWriter(h.writer)
+	logutil.LogJSON.Store(false)
+	exclusive.Unlock()
+}
+
+// freePort returns a port that is free to listen on.
+func freePort(t testing.TB) uint {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening on a free port: %v", err)
+	}
+	defer l.Close()
+
+	return uint(l.Addr().(*net.TCPAddr).Port) //nolint:forcetypeassert // always TCP
+}
@@ -1,17 +1,17 @@
 package main
 
 import (
-	"context"
 	"flag"
-	"fmt"
 	"os"
 	"strings"
 	"sync"
 
 	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/gpm/internal/check"
 	"github.com/ricochhet/gpm/internal/custom"
 	"github.com/ricochhet/gpm/internal/proc"
 	"github.com/ricochhet/pkg/cmdutil"
+	"github.com/ricochhet/pkg/cueutil"
 	"github.com/ricochhet/pkg/errutil"
 	"github.com/ricochhet/pkg/logutil"
 	"github.com/ricochhet/pkg/maputil"
@@ -27,41 +27,13 @@ const (
 	revision = "HEAD"
 )
 
-func usage() {
-	fmt.Fprint(os.Stderr, `Tasks:
-  gpm console                    # Start a minimal command console
-  gpm check                      # Show entries in Taskfile
-  gpm help [TASK]                # Show this help
-  gpm export [FORMAT] [LOCATION] # Export the apps to another process
-                                       (upstart)
-  gpm run COMMAND [PROCESS...]   # Run a command
-                                       start
-                                       stop
-                                       stop-all
-                                       restart
-                                       restart-all
-                                       list
-                                       status
-  gpm start [PROCESS]            # Start the application
-  gpm runas [PROCESS]            # Run a runas process
-  gpm version                    # Display gpm version
-
-Options:
-`)
-	flag.PrintDefaults()
-
-	if console {
-		return
-	}
-
-	os.Exit(0)
-}
-
 var (
-	mu        sync.Mutex
-	ataskfile config.Taskfile
-	ctx       proc.Context
-	console   = false
+	mu            sync.Mutex
+	ataskfile     config.Taskfile
+	taskfilePaths []string
+	skipped       []check.Skipped
+	ctx           proc.Context
+	console       = false
 )
 
 // showVersion shows the current version of gpm.
@@ -81,6 +53,8 @@ func main() {
 	cfg := readConfig()
 
 	logutil.LogTime.Store(cfg.LogTime)
+	logutil.NoColor.Store(cfg.NoColor || os.Getenv("NO_COLOR") != "")
+	exitOnErr(setLogFormat(cfg.LogFormat))
 	logutil.MaxProcNameLength.Store(0)
 
 	if cfg.BaseDir != "" {
@@ -97,6 +71,20 @@ func main() {
 		SharedProc: config.NewProcManager(),
 		StoredProc: config.NewProcManager(),
 		Builtins:   custom.NewDefaultBuiltins(),
+		Terminals:  proc.NewTerminals(),
+		States:     proc.NewStates(),
+		Agents:     proc.NewAgents(),
+	}
+
+	// Completion reads the Taskfile itself, if there is one.
+	if isCompletion() {
+		if ctx.Flags.Args[0] == completeCommand {
+			exitOnErr(completeWords(ctx.Flags.Args[1:]))
+		} else {
+			exitOnErr(commands())
+		}
+
+		return
 	}
 
 	err = readTaskfile()
@@ -109,78 +97,88 @@ func main() {
 		usage()
 	}
 
-	cmd := ctx.Flags.Args[0]
-	switch cmd {
-	case "console":
-		console = true
+	exitOnErr(commands())
+}
+
+// command: console.
+func runConsole() error {
+	if console {
+		return nil
+	}
+
+	console = true
+
+	cmdutil.NewScanner(func(i string) error {
 		fs := flag.NewFlagSet("console", flag.ContinueOnError)
 
 		var f config.Flags
 
-		cmdutil.NewScanner(func(i string) error {
-			registerFlags(fs, &f)
+		registerFlags(fs, &f)
 
-			if err := fs.Parse(strings.Fields(i)); err != nil {
-				return errutil.New("fs.Parse", err)
-			}
+		if err := fs.Parse(strings.Fields(i)); err != nil {
+			return errutil.New("fs.Parse", err)
+		}
 
-			ctx.Flags = maputil.Merge(ctx.Flags, &f, "json", true)
-			ctx.Flags.Args = fs.Args()
-			cmd := ctx.Flags.Args[0]
+		ctx.Flags = maputil.Merge(ctx.Flags, &f, "json", true)
+		ctx.Flags.Args = fs.Args()
 
-			if strings.EqualFold(cmd, "exit") || strings.EqualFold(cmd, "q") {
-				os.Exit(0)
-				return nil
-			}
+		if len(ctx.Flags.Args) == 0 {
+			return nil
+		}
 
-			ctx.SharedProc.CopyFrom(ctx.StoredProc)
+		cmd := ctx.Flags.Args[0]
 
-			return commands()
-		})
-	default:
-		err = commands()
-	}
+		if strings.EqualFold(cmd, "exit") || strings.EqualFold(cmd, "q") {
+			os.Exit(0)
+			return nil
+		}
 
-	exitOnErr(err)
+		ctx.SharedProc.CopyFrom(ctx.StoredProc)
+
+		return commands()
+	})
+
+	return nil
 }
 
-// commands runs functions based on the provided Flags.Args[0].
+// commands runs the command of Flags.Args[0], see commandList.
 func commands() error {
-	var err error
-
-	cmd := ctx.Flags.Args[0]
-	switch cmd {
-	case "check":
-		err = ctx.Check()
-	case "help":
-		usage()
-	case "run":
-		if len(ctx.Flags.Args) >= 2 {
-			cmd, args := ctx.Flags.Args[1], ctx.Flags.Args[2:]
-			err = proc.Run(cmd, args, ctx.Flags.Port)
-		} else {
-			usage()
-		}
-	case "export":
-		if len(ctx.Flags.Args) == 3 {
-			format, path := ctx.Flags.Args[1], ctx.Flags.Args[2]
-			err = export(format, path)
-		} else {
-			usage()
-		}
-	case "start":
-		nc, stop := proc.NotifyCh()
-		defer stop()
-
-		err = ctx.Start(context.Background(), nc, ctx.Flags)
-	case "runas":
-		_, err = ctx.Runas(ataskfile.Runas) // Returned boolean is unneeded here.
-	case "version":
-		showVersion()
-	default:
+	cmd := findCommand(commandList(), ctx.Flags.Args[0])
+	if cmd == nil {
 		usage()
+		return nil
 	}
 
+	return errutil.WithFrame(cmd.exec(ctx.Flags.Args[1:]))
+}
+
+// command: check.
+func checkTaskfile() error {
+	return ctx.Check(&check.Linter{
+		Taskfile:   ataskfile,
+		Paths:      taskfilePaths,
+		Builtins:   ctx.Builtins.Names(),
+		Compile:    *cueutil.NewBuiltins([]string{}).Map(),
+		PublicKeys: ctx.Flags.Download.PublicKeys,
+		Skipped:    skipped,
+	})
+}
+
+// command: pull.
+func pull() error {
+	logger := logutil.NewLogger("pull", 0)
+
+	_, err := ctx.Builtins.Start(logger, ctx.Builtins.Download, *ctx.Flags)
+
+	return errutil.WithFrame(err)
+}
+
+// command: prune.
+func prune() error {
+	logger := logutil.NewLogger("prune", 0)
+
+	_, err := ctx.Builtins.Start(logger, ctx.Builtins.Remove, *ctx.Flags)
+
 	return errutil.WithFrame(err)
 }
 
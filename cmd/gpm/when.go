@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ricochhet/gpm/config"
+	"github.com/ricochhet/pkg/maputil"
+)
+
+// skipReason returns why the task is not included, or an empty string if it is.
+func skipReason(task config.Task) string {
+	if len(task.Platforms) != 0 && !slices.Contains(task.Platforms, runtime.GOOS) {
+		return fmt.Sprintf("platform %s is not one of %s", runtime.GOOS, strings.Join(task.Platforms, ", "))
+	}
+
+	when := task.When
+	if when.If != nil && !*when.If {
+		return "when.if is false"
+	}
+
+	flags := maputil.StructToMap(ctx.Flags, "json", true)
+
+	conditions := []struct {
+		name    string
+		entries []string
+		fn      func(string) bool
+	}{
+		{"exists", when.Exists, whenExists},
+		{"env", when.Env, whenEnv},
+		{"flags", when.Flags, func(name string) bool {
+			v, ok := flags[name]
+			return ok && !maputil.IsZero(v)
+		}},
+		{"pulled", when.Pulled, func(key string) bool {
+			ok, err := ctx.Builtins.Pulled(key)
+			return err == nil && ok
+		}},
+	}
+
+	for _, c := range conditions {
+		for _, entry := range c.entries {
+			name, negate := strings.CutPrefix(entry, "!")
+			if c.fn(name) == negate {
+				return fmt.Sprintf("when.%s %q is false", c.name, entry)
+			}
+		}
+	}
+
+	return ""
+}
+
+// whenExists returns true if the path, or glob pattern, matches any file.
+func whenExists(pattern string) bool {
+	matches, err := filepath.Glob(pattern)
+
+	return err == nil && len(matches) != 0
+}
+
+// whenEnv returns true if 'NAME' is set and not empty, or 'NAME=value' is set to value.
+func whenEnv(entry string) bool {
+	name, value, ok := strings.Cut(entry, "=")
+	if !ok {
+		return os.Getenv(name) != ""
+	}
+
+	v, set := os.LookupEnv(name)
+
+	return set && v == value
+}
@@ -0,0 +1,20 @@
+package byteutil
+
+import "fmt"
+
+// FormatSize returns a human readable representation of n bytes, using binary (IEC) units.
+func FormatSize(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
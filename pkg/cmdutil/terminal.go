@@ -0,0 +1,13 @@
+package cmdutil
+
+import "os"
+
+// IsTerminal returns true if the file is a character device, such as a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -13,13 +13,33 @@ import (
 	"hash/crc64"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/ricochhet/pkg/errutil"
 	"github.com/ricochhet/pkg/murmur3"
 	"github.com/ricochhet/pkg/strutil"
 )
 
-var ErrHashNotEqual = errors.New("hash is not equal")
+var (
+	ErrHashNotEqual = errors.New("hash is not equal")
+	ErrUnknownHash  = errors.New("unknown hash algorithm")
+)
+
+// NewHasher returns a new hash.Hash by algorithm name, e.g. 'sha256'.
+func NewHasher(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, errutil.WithFramef("%w: %s", ErrUnknownHash, algorithm)
+	}
+}
 
 // NewHash creates a new hash for a file at the specified path.
 func NewHash(path string, hash hash.Hash) (string, error) {
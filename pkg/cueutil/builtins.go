@@ -2,6 +2,9 @@ package cueutil
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/ricochhet/pkg/timeutil"
 )
@@ -10,15 +13,30 @@ type Builtins[T any] struct {
 	Args             []T
 	WorkingDirectory string
 	Time             string
+	// Host, values are empty if they cannot be determined.
+	OS       string
+	Arch     string
+	ExeDir   string
+	Home     string
+	PathSep  string
+	Hostname string
+	Env      map[string]string
 }
 
 // Map returns a map of the provided Builtins receiver.
 func (b *Builtins[T]) Map() *map[string]any {
 	return &map[string]any{
 		"builtin": map[string]any{
-			"args": b.Args,
-			"cwd":  b.WorkingDirectory,
-			"time": b.Time,
+			"args":     b.Args,
+			"cwd":      b.WorkingDirectory,
+			"time":     b.Time,
+			"os":       b.OS,
+			"arch":     b.Arch,
+			"exeDir":   b.ExeDir,
+			"home":     b.Home,
+			"pathSep":  b.PathSep,
+			"hostname": b.Hostname,
+			"env":      b.Env,
 		},
 	}
 }
@@ -26,10 +44,37 @@ func (b *Builtins[T]) Map() *map[string]any {
 // NewBuiltins returns a new Builtins.
 func NewBuiltins[T any](args []T) *Builtins[T] {
 	wd, _ := os.Getwd()
+	home, _ := os.UserHomeDir()
+	hostname, _ := os.Hostname()
+
+	exeDir := ""
+	if exe, err := os.Executable(); err == nil {
+		exeDir = filepath.Dir(exe)
+	}
 
 	return &Builtins[T]{
 		Args:             args,
 		WorkingDirectory: wd,
 		Time:             timeutil.NewDefaultTimestamp(),
+		OS:               runtime.GOOS,
+		Arch:             runtime.GOARCH,
+		ExeDir:           exeDir,
+		Home:             home,
+		PathSep:          string(os.PathSeparator),
+		Hostname:         hostname,
+		Env:              environ(),
 	}
 }
+
+// environ returns the environment as a map.
+func environ() map[string]string {
+	env := map[string]string{}
+
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			env[k] = v
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,133 @@
+package cueutil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+	"github.com/ricochhet/pkg/errutil"
+)
+
+type SchemaError struct {
+	Filename string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+type Schema struct {
+	Data       []byte
+	Definition string
+	Map        map[string]any
+}
+
+// NewSchema returns a Schema that validates against the definition in data.
+func NewSchema(data []byte, definition string) *Schema {
+	return &Schema{
+		Data:       data,
+		Definition: definition,
+	}
+}
+
+// Compile sets the receivers compile to the provided map.
+func (s *Schema) Compile(c map[string]any) {
+	s.Map = c
+}
+
+// File validates the file at the specified path against the schema.
+func (s *Schema) File(path string) ([]SchemaError, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errutil.New("os.ReadFile", err)
+	}
+
+	return s.Bytes(path, data)
+}
+
+// Bytes validates data against the schema, returning a SchemaError for every
+// violation. The filename is used to resolve positions within data.
+func (s *Schema) Bytes(filename string, data []byte) ([]SchemaError, error) {
+	ctx := cuecontext.New()
+
+	schema := ctx.CompileBytes(s.Data, cue.Filename("schema.cue"))
+	if schema.Err() != nil {
+		return nil, errutil.New("ctx.CompileBytes (schema)", schema.Err())
+	}
+
+	def := schema.LookupPath(cue.ParsePath(s.Definition))
+	if !def.Exists() {
+		return nil, errutil.WithFramef("definition does not exist: %s", s.Definition)
+	}
+
+	v := ctx.CompileBytes(data, cue.Filename(filename))
+	if v.Err() != nil {
+		return nil, errutil.New("ctx.CompileBytes", v.Err())
+	}
+
+	if s.Map != nil {
+		v = v.Unify(ctx.Encode(s.Map))
+	}
+
+	err := def.Unify(v).Validate()
+	if err == nil {
+		return nil, nil
+	}
+
+	errs := errors.Errors(err)
+	result := make([]SchemaError, 0, len(errs))
+
+	for _, e := range errs {
+		format, args := e.Msg()
+		pos := position(filename, e)
+
+		result = append(result, SchemaError{
+			Filename: pos.Filename(),
+			Line:     pos.Line(),
+			Column:   pos.Column(),
+			Path:     strings.Join(trimDefinition(e.Path()), "."),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	return result, nil
+}
+
+// String returns a string representation of the receiver.
+func (e SchemaError) String() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Filename, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", e.Filename, e.Line, e.Column, e.Message, e.Path)
+}
+
+// position returns the first position of err that is within filename,
+// falling back to the first known position.
+func position(filename string, err errors.Error) token.Pos {
+	positions := err.InputPositions()
+	for _, pos := range positions {
+		if pos.Filename() == filename {
+			return pos
+		}
+	}
+
+	if len(positions) != 0 {
+		return positions[0]
+	}
+
+	return err.Position()
+}
+
+// trimDefinition removes the definition the error was reported against from path.
+func trimDefinition(path []string) []string {
+	if len(path) != 0 && strings.HasPrefix(path[0], "#") {
+		return path[1:]
+	}
+
+	return path
+}
@@ -0,0 +1,107 @@
+package dlutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+var ErrChecksumNotFound = errors.New("checksum not found")
+
+// maxChecksumFileSize limits the size of fetched checksum and signature files.
+const maxChecksumFileSize = 1 << 20
+
+// FetchChecksum fetches a checksum file, e.g. 'SHA256SUMS' or 'file.tar.gz.sha512', and
+// returns the digest of filename. Both GNU ('<hex>  <name>') and BSD ('SHA256 (<name>) = <hex>')
+// formats are supported, as well as files containing only a checksum.
+func FetchChecksum(ctx context.Context, client *http.Client, url, filename string) (Digest, error) {
+	b, err := fetchSmall(ctx, client, url)
+	if err != nil {
+		return Digest{}, errutil.WithFrame(err)
+	}
+
+	digest, ok := parseChecksums(string(b), filename)
+	if !ok {
+		return Digest{}, errutil.WithFramef("%w: %s in %s", ErrChecksumNotFound, filename, url)
+	}
+
+	return digest, nil
+}
+
+// parseChecksums returns the digest of filename within a checksum file.
+func parseChecksums(data, filename string) (Digest, bool) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		// BSD style: 'SHA256 (name) = hex'.
+		if algorithm, rest, ok := strings.Cut(line, " ("); ok {
+			name, sum, ok := strings.Cut(rest, ") = ")
+			if ok && path.Base(name) == filename {
+				d, err := ParseDigest(strings.ToLower(algorithm) + ":" + sum)
+				return d, err == nil
+			}
+
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		var sum string
+
+		switch {
+		case len(fields) == 1 && len(lines) == 1:
+			sum = fields[0]
+		case len(fields) == 2 && path.Base(strings.TrimLeft(fields[1], "*")) == filename:
+			sum = fields[0]
+		default:
+			continue
+		}
+
+		algorithm, ok := digestAlgorithm(sum)
+		if !ok {
+			return Digest{}, false
+		}
+
+		d, err := ParseDigest(algorithm + ":" + sum)
+
+		return d, err == nil
+	}
+
+	return Digest{}, false
+}
+
+// fetchSmall returns the body of a small file, such as a checksum or signature.
+func fetchSmall(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errutil.New("http.NewRequestWithContext", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errutil.New("client.Do", err)
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res); err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxChecksumFileSize))
+	if err != nil {
+		return nil, errutil.New("io.ReadAll", err)
+	}
+
+	return b, nil
+}
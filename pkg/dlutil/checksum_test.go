@@ -0,0 +1,72 @@
+package dlutil_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ricochhet/pkg/dlutil"
+)
+
+func TestFetchChecksum(t *testing.T) {
+	t.Parallel()
+
+	sum256 := strings.Repeat("ab", sha256.Size)
+	sum512 := strings.Repeat("cd", sha512.Size)
+	other := strings.Repeat("ef", sha256.Size)
+
+	tests := []struct {
+		name string
+		file string
+		want dlutil.Digest
+		err  error
+	}{
+		{"gnu", other + "  other.tar.gz\n" + sum256 + "  app.tar.gz\n", dlutil.Digest{Algorithm: "sha256", Sum: sum256}, nil},
+		{"gnu binary mode", sum256 + " *app.tar.gz\n", dlutil.Digest{Algorithm: "sha256", Sum: sum256}, nil},
+		{"gnu with directory", sum256 + "  dist/app.tar.gz\n", dlutil.Digest{Algorithm: "sha256", Sum: sum256}, nil},
+		{"gnu sha512", sum512 + "  app.tar.gz\n", dlutil.Digest{Algorithm: "sha512", Sum: sum512}, nil},
+		{"bsd", "SHA512 (app.tar.gz) = " + sum512 + "\n", dlutil.Digest{Algorithm: "sha512", Sum: sum512}, nil},
+		{"checksum only", sum256 + "\n", dlutil.Digest{Algorithm: "sha256", Sum: sum256}, nil},
+		{"crlf", other + "  other.tar.gz\r\n" + sum256 + "  app.tar.gz\r\n", dlutil.Digest{Algorithm: "sha256", Sum: sum256}, nil},
+		{"missing", other + "  other.tar.gz\n", dlutil.Digest{}, dlutil.ErrChecksumNotFound},
+		{"empty", "", dlutil.Digest{}, dlutil.ErrChecksumNotFound},
+		{"unknown length", "abcd  app.tar.gz\n", dlutil.Digest{}, dlutil.ErrChecksumNotFound},
+		{"bsd not hex", "SHA256 (app.tar.gz) = " + strings.Repeat("zz", sha256.Size), dlutil.Digest{}, dlutil.ErrChecksumNotFound},
+		{"bsd unknown algorithm", "MD5 (app.tar.gz) = " + sum256, dlutil.Digest{}, dlutil.ErrChecksumNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(tt.file))
+			}))
+			defer srv.Close()
+
+			got, err := dlutil.FetchChecksum(t.Context(), srv.Client(), srv.URL+"/SHASUMS", "app.tar.gz")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, wanted %v", err, tt.err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %+v, wanted %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchChecksumStatus(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := dlutil.FetchChecksum(t.Context(), srv.Client(), srv.URL, "app.tar.gz"); err == nil {
+		t.Error("got no error for a missing checksum file")
+	}
+}
@@ -0,0 +1,138 @@
+package dlutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+var ErrInvalidCertificate = errors.New("no certificates found")
+
+type ClientOptions struct {
+	// Timeout limits each request, including reading the response body. Zero means no timeout.
+	Timeout time.Duration
+	// ConnectTimeout limits establishing a connection. Zero uses the default.
+	ConnectTimeout time.Duration
+	// Proxy is the URL of the proxy to use. If empty, the proxy is read from the environment.
+	Proxy string
+	// CAFiles are PEM files of root certificates trusted in addition to the system pool.
+	CAFiles []string
+	// Headers are added to every request by host name, e.g. an Authorization header.
+	Headers map[string]map[string]string
+	// Netrc is the path of a netrc file used for basic authentication. Empty disables netrc.
+	Netrc string
+}
+
+// NewClient creates an http.Client from the options.
+func NewClient(opts ClientOptions) (*http.Client, error) {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errutil.WithFramef("unexpected default transport")
+	}
+
+	transport = transport.Clone()
+
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, errutil.New("url.Parse", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.ConnectTimeout != 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   opts.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	if len(opts.CAFiles) != 0 {
+		pool, err := certPool(opts.CAFiles)
+		if err != nil {
+			return nil, errutil.WithFrame(err)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	var machines []netrcMachine
+
+	if opts.Netrc != "" {
+		var err error
+
+		machines, err = readNetrc(opts.Netrc)
+		if err != nil {
+			return nil, errutil.WithFrame(err)
+		}
+	}
+
+	return &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &authTransport{
+			base:     transport,
+			headers:  opts.Headers,
+			machines: machines,
+		},
+	}, nil
+}
+
+// certPool returns the system certificate pool, with the certificates of files appended.
+func certPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errutil.New("os.ReadFile", err)
+		}
+
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errutil.WithFramef("%w: %s", ErrInvalidCertificate, file)
+		}
+	}
+
+	return pool, nil
+}
+
+// authTransport adds headers and netrc credentials by host. It is applied to
+// every request, including redirects, so credentials never leak to other hosts.
+type authTransport struct {
+	base     http.RoundTripper
+	headers  map[string]map[string]string
+	machines []netrcMachine
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	headers := t.headers[host]
+
+	login, password, ok := netrcLogin(t.machines, host)
+	if len(headers) == 0 && !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	if ok && req.Header.Get("Authorization") == "" {
+		req.SetBasicAuth(login, password)
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,101 @@
+package dlutil
+
+import (
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strings"
+
+	"github.com/ricochhet/pkg/cryptoutil"
+	"github.com/ricochhet/pkg/errutil"
+)
+
+var ErrInvalidDigest = errors.New("invalid digest")
+
+// Digest is an expected checksum, written as 'sha512:<hex>'. A digest without
+// an algorithm prefix is a sha256.
+type Digest struct {
+	Algorithm string
+	Sum       string
+}
+
+// ParseDigest parses s as a Digest. An empty s returns the zero Digest.
+func ParseDigest(s string) (Digest, error) {
+	if s == "" {
+		return Digest{}, nil
+	}
+
+	algorithm, sum, ok := strings.Cut(s, ":")
+	if !ok {
+		algorithm, sum = "sha256", s
+	}
+
+	d := Digest{Algorithm: strings.ToLower(algorithm), Sum: strings.ToLower(sum)}
+
+	h, err := d.Hasher()
+	if err != nil {
+		return Digest{}, errutil.WithFrame(err)
+	}
+
+	if b, err := hex.DecodeString(d.Sum); err != nil || len(b) != h.Size() {
+		return Digest{}, errutil.WithFramef("%w: %s", ErrInvalidDigest, s)
+	}
+
+	return d, nil
+}
+
+// IsZero returns true if no digest is set.
+func (d Digest) IsZero() bool {
+	return d.Sum == ""
+}
+
+// String returns the digest as 'algorithm:sum'.
+func (d Digest) String() string {
+	return d.Algorithm + ":" + d.Sum
+}
+
+// Hasher returns a new hash.Hash for the algorithm of the digest.
+func (d Digest) Hasher() (hash.Hash, error) {
+	h, err := cryptoutil.NewHasher(d.Algorithm)
+	if err != nil {
+		return nil, errutil.New("cryptoutil.NewHasher", err)
+	}
+
+	return h, nil
+}
+
+// Equal returns true if sum, hex encoded, matches the digest.
+func (d Digest) Equal(sum []byte) bool {
+	return hex.EncodeToString(sum) == d.Sum
+}
+
+// Verify returns an error if the file at path does not match the digest.
+func (d Digest) Verify(path string) error {
+	h, err := d.Hasher()
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	sum, err := cryptoutil.NewHash(path, h)
+	if err != nil {
+		return errutil.New("cryptoutil.NewHash", err)
+	}
+
+	if sum != d.Sum {
+		return errutil.WithFramef("%w: %q (%q) expected %q", ErrFileHashNoMatch, path, sum, d.Sum)
+	}
+
+	return nil
+}
+
+// digestAlgorithm returns the algorithm of a bare hex checksum by its length.
+func digestAlgorithm(sum string) (string, bool) {
+	switch len(sum) {
+	case 64:
+		return "sha256", true
+	case 128:
+		return "sha512", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,92 @@
+package dlutil_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ricochhet/pkg/dlutil"
+)
+
+func TestParseDigest(t *testing.T) {
+	t.Parallel()
+
+	sha256Sum := strings.Repeat("ab", sha256.Size)
+	sha512Sum := strings.Repeat("cd", sha512.Size)
+
+	tests := []struct {
+		name string
+		in   string
+		want dlutil.Digest
+		err  bool
+	}{
+		{"empty", "", dlutil.Digest{}, false},
+		{"bare sha256", sha256Sum, dlutil.Digest{Algorithm: "sha256", Sum: sha256Sum}, false},
+		{"prefixed sha256", "sha256:" + sha256Sum, dlutil.Digest{Algorithm: "sha256", Sum: sha256Sum}, false},
+		{"prefixed sha512", "sha512:" + sha512Sum, dlutil.Digest{Algorithm: "sha512", Sum: sha512Sum}, false},
+		{"uppercase", "SHA256:" + strings.ToUpper(sha256Sum), dlutil.Digest{Algorithm: "sha256", Sum: sha256Sum}, false},
+		{"unknown algorithm", "md4:" + sha256Sum, dlutil.Digest{}, true},
+		{"short sum", "sha256:abcd", dlutil.Digest{}, true},
+		{"sha512 sum as sha256", "sha256:" + sha512Sum, dlutil.Digest{}, true},
+		{"not hex", "sha256:" + strings.Repeat("zz", sha256.Size), dlutil.Digest{}, true},
+		{"empty sum", "sha256:", dlutil.Digest{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := dlutil.ParseDigest(tt.in)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, wanted error %t", err, tt.err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %+v, wanted %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestVerify(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("gpm"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sum256 := sha256.Sum256([]byte("gpm"))
+	sum512 := sha512.Sum512([]byte("gpm"))
+	other := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name   string
+		digest string
+		err    error
+	}{
+		{"sha256", hex.EncodeToString(sum256[:]), nil},
+		{"sha512", "sha512:" + hex.EncodeToString(sum512[:]), nil},
+		{"mismatch", hex.EncodeToString(other[:]), dlutil.ErrFileHashNoMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := dlutil.ParseDigest(tt.digest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := d.Verify(path); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, wanted %v", err, tt.err)
+			}
+		})
+	}
+}
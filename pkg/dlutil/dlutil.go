@@ -2,15 +2,17 @@ package dlutil
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
 
 	"github.com/avast/retry-go"
 	"github.com/ricochhet/pkg/errutil"
@@ -19,14 +21,19 @@ import (
 )
 
 var (
-	ErrDownloadURLEmpty  = errors.New("download url is empty")
-	ErrDownloadPathEmpty = errors.New("download path is empty")
-	ErrDownloadNameEmpty = errors.New("download name is empty")
-	ErrFileHashNoMatch   = errors.New("file hash does not match")
+	ErrDownloadURLEmpty    = errors.New("download url is empty")
+	ErrDownloadPathEmpty   = errors.New("download path is empty")
+	ErrDownloadNameEmpty   = errors.New("download name is empty")
+	ErrFileHashNoMatch     = errors.New("file hash does not match")
+	ErrUnexpectedStatus    = errors.New("unexpected response status")
+	ErrInvalidContentRange = errors.New("invalid content range")
 )
 
 type Messenger struct {
 	Start func(string)
+	// Progress is called with the bytes written so far, and the total (-1 if unknown).
+	Progress func(name string, written, total int64)
+	Done     func(string)
 }
 
 type Download struct {
@@ -34,6 +41,13 @@ type Download struct {
 	Directory string
 	Filename  string
 	SHA256    string
+	// Checksum is the expected digest, e.g. 'sha512:<hex>', see ParseDigest. SHA256 is used if empty.
+	Checksum string
+	// Signature is the URL of a detached ed25519 signature, verified against PublicKeys.
+	Signature  string
+	PublicKeys []ed25519.PublicKey
+	// Client is used for requests, http.DefaultClient if nil. See NewClient.
+	Client *http.Client
 }
 
 var retryOpts = []retry.Option{
@@ -58,27 +72,44 @@ func NewDefaultMessenger() Messenger {
 	}
 }
 
-// NewDefaultValidator creates a default validator function using SHA256.
+// NewDefaultValidator creates a default validator function using the digest of the receiver.
+// If the receiver has a signature, an existing file is only valid if it also matches it.
 func (d *Download) NewDefaultValidator(path string) error {
-	if fsutil.Exists(path) {
-		if d.SHA256 == "" {
-			return nil
-		}
+	if !fsutil.Exists(path) {
+		return ErrFileHashNoMatch
+	}
 
-		b, err := os.ReadFile(path)
-		if err == nil {
-			sha := sha256.New()
-			sha.Write(b)
-			shasum := hex.EncodeToString(sha.Sum(nil))
+	digest, err := d.digest()
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
 
-			if strings.ToLower(d.SHA256) == shasum {
-				logutil.Infof(os.Stdout, "Ok: %s\n", d.Filename)
-				return nil
-			}
+	if !digest.IsZero() {
+		if err := digest.Verify(path); err != nil {
+			return ErrFileHashNoMatch
 		}
 	}
 
-	return ErrFileHashNoMatch
+	if d.Signature != "" {
+		if err := d.verifySignature(context.Background(), path); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
+	if !digest.IsZero() || d.Signature != "" {
+		logutil.Infof(os.Stdout, "Ok: %s\n", d.Filename)
+	}
+
+	return nil
+}
+
+// digest returns the expected digest of the receiver.
+func (d *Download) digest() (Digest, error) {
+	if d.Checksum != "" {
+		return ParseDigest(d.Checksum)
+	}
+
+	return ParseDigest(d.SHA256)
 }
 
 // Download downloads a file.
@@ -91,6 +122,15 @@ func (d *Download) Download(
 		return errutil.New("validateParams", err)
 	}
 
+	digest, err := d.digest()
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	if validator == nil {
+		digest = Digest{}
+	}
+
 	path := filepath.Join(d.Directory, d.Filename)
 	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return errutil.New("os.MkdirAll", err)
@@ -105,45 +145,137 @@ func (d *Download) Download(
 
 		messenger.Start(d.Filename)
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.URL, nil)
-		if err != nil {
-			return errutil.New("http.NewRequestWithContext", err)
-		}
+		return d.fetch(ctx, path, messenger, digest)
+	}, append(slices.Clone(retryOpts), retry.Context(ctx))...)
+}
 
-		res, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return errutil.New("http.DefaultClient.Do", err)
-		}
-		defer res.Body.Close()
+// fetch downloads the file to path, resuming a partial download if the server supports it.
+// If digest is zero, the checksum is not verified.
+func (d *Download) fetch(ctx context.Context, path string, messenger Messenger, digest Digest) error {
+	tmp := path + ".tmp"
 
-		tmp := path + ".tmp"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.URL, nil)
+	if err != nil {
+		return errutil.New("http.NewRequestWithContext", err)
+	}
 
-		file, err := os.Create(tmp)
-		if err != nil {
-			return errutil.New("os.Create", err)
-		}
+	offset, validator := readPartial(tmp)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return errutil.New("client.Do", err)
+	}
+	defer res.Body.Close()
 
-		if err := d.writeResp(res, file, validator == nil || d.SHA256 == ""); err != nil {
-			file.Close()
-			os.Remove(tmp)
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		removePartial(tmp) // Restart from zero on the next attempt.
+		return errutil.WithFramef("%w: %s", ErrUnexpectedStatus, res.Status)
+	}
 
-			return errutil.New("writeResp", err)
+	if err := checkStatus(res); err != nil {
+		return err
+	}
+
+	// The server ignored the range, or the file changed since the partial download.
+	if res.StatusCode != http.StatusPartialContent {
+		offset = 0
+	} else if start, ok := contentRangeStart(res); !ok || start != offset {
+		removePartial(tmp)
+		return errutil.WithFramef("%w: %s", ErrInvalidContentRange, res.Header.Get("Content-Range"))
+	}
+
+	if err := writePartialValidator(tmp, res); err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(tmp, flag, 0o644)
+	if err != nil {
+		return errutil.New("os.OpenFile", err)
+	}
+
+	// Keep the partial file on failure, so the next attempt can resume it.
+	if err := d.writeResp(res, file, tmp, offset, messenger, digest); err != nil {
+		file.Close()
+
+		if errors.Is(err, ErrFileHashNoMatch) {
+			removePartial(tmp)
 		}
 
-		if err := file.Close(); err != nil {
-			os.Remove(tmp)
-			return errutil.New("file.Close", err)
+		return errutil.New("writeResp", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return errutil.New("file.Close", err)
+	}
+
+	if d.Signature != "" {
+		if err := d.verifySignature(ctx, tmp); err != nil {
+			removePartial(tmp)
+			return retry.Unrecoverable(errutil.WithFrame(err))
 		}
+	}
 
-		return os.Rename(tmp, path)
-	}, retryOpts...)
+	if err := os.Rename(tmp, path); err != nil {
+		return errutil.New("os.Rename", err)
+	}
+
+	removePartial(tmp)
+
+	if messenger.Done != nil {
+		messenger.Done(d.Filename)
+	}
+
+	return nil
 }
 
-// writeResp writes the response to the given file path.
-func (d *Download) writeResp(resp *http.Response, flags *os.File, skip bool) error {
-	sha := sha256.New()
+// writeResp writes the response to the file. If offset is not zero, the response
+// is appended to the first offset bytes of the partial file, which are hashed first.
+func (d *Download) writeResp(
+	resp *http.Response,
+	flags *os.File,
+	partial string,
+	offset int64,
+	messenger Messenger,
+	digest Digest,
+) error {
+	sha := hash.Hash(sha256.New())
+	if !digest.IsZero() {
+		var err error
+
+		if sha, err = digest.Hasher(); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
 	buf := make([]byte, 1<<20)
 
+	if offset > 0 {
+		if err := hashPartial(sha, partial, offset); err != nil {
+			return errutil.WithFrame(err)
+		}
+	}
+
+	written := offset
+
+	total := resp.ContentLength
+	if total >= 0 {
+		total += offset
+	}
+
 	for {
 		n, err := resp.Body.Read(buf)
 		if err != nil && err != io.EOF {
@@ -161,20 +293,25 @@ func (d *Download) writeResp(resp *http.Response, flags *os.File, skip bool) err
 		if _, err := sha.Write(buf[:n]); err != nil {
 			return errutil.New("sha.Write", err)
 		}
+
+		written += int64(n)
+		if messenger.Progress != nil {
+			messenger.Progress(d.Filename, written, total)
+		}
 	}
 
-	if skip {
+	if digest.IsZero() {
 		return nil
 	}
 
-	shasum := hex.EncodeToString(sha.Sum(nil))
-	if strings.ToLower(d.SHA256) != shasum {
+	if sum := sha.Sum(nil); !digest.Equal(sum) {
 		return errutil.WithFrame(
 			fmt.Errorf(
-				"mismatch: %q (%q) expected %q",
+				"%w: %q (%q) expected %q",
+				ErrFileHashNoMatch,
 				d.Filename,
-				strings.ToLower(d.SHA256),
-				shasum,
+				hex.EncodeToString(sum),
+				digest.Sum,
 			),
 		)
 	}
@@ -182,6 +319,25 @@ func (d *Download) writeResp(resp *http.Response, flags *os.File, skip bool) err
 	return nil
 }
 
+// checkStatus returns an error if the response is not successful. Client errors,
+// other than timeouts and rate limits, are not retried.
+func checkStatus(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	err := errutil.WithFramef("%w: %s", ErrUnexpectedStatus, res.Status)
+
+	switch {
+	case res.StatusCode == http.StatusRequestTimeout,
+		res.StatusCode == http.StatusTooManyRequests,
+		res.StatusCode >= 500:
+		return err
+	default:
+		return retry.Unrecoverable(err)
+	}
+}
+
 // ensureDownloadParams ensures the values of the download parameters are not empty.
 func (d *Download) ensureDownloadParams() error {
 	if d.URL == "" {
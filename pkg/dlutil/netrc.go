@@ -0,0 +1,89 @@
+package dlutil
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+type netrcMachine struct {
+	Name     string // Empty for the default entry.
+	Login    string
+	Password string
+}
+
+// readNetrc parses the machine and default entries of a netrc file.
+func readNetrc(path string) ([]netrcMachine, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errutil.New("os.ReadFile", err)
+	}
+
+	var (
+		machines []netrcMachine
+		current  *netrcMachine
+	)
+
+	lines := strings.Split(string(b), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+
+		for j := 0; j < len(fields); j++ {
+			switch fields[j] {
+			case "machine", "default":
+				machines = append(machines, netrcMachine{})
+				current = &machines[len(machines)-1]
+
+				if fields[j] == "machine" && j+1 < len(fields) {
+					j++
+					current.Name = fields[j]
+				}
+			case "login", "password", "account":
+				if current == nil || j+1 >= len(fields) {
+					continue
+				}
+
+				j++
+
+				switch fields[j-1] {
+				case "login":
+					current.Login = fields[j]
+				case "password":
+					current.Password = fields[j]
+				}
+			case "macdef":
+				// Macro definitions run until the next empty line.
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+
+				j = len(fields)
+			}
+		}
+	}
+
+	return machines, nil
+}
+
+// netrcLogin returns the credentials for host, falling back to the default entry.
+func netrcLogin(machines []netrcMachine, host string) (string, string, bool) {
+	var fallback *netrcMachine
+
+	for i, m := range machines {
+		if m.Name == host {
+			return m.Login, m.Password, true
+		}
+
+		if m.Name == "" && fallback == nil {
+			fallback = &machines[i]
+		}
+	}
+
+	if fallback != nil {
+		return fallback.Login, fallback.Password, true
+	}
+
+	return "", "", false
+}
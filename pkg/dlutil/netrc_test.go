@@ -0,0 +1,74 @@
+package dlutil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ricochhet/pkg/dlutil"
+)
+
+func TestNetrc(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		netrc    string
+		login    string
+		password string
+		ok       bool
+	}{
+		{"machine", "machine 127.0.0.1 login user password secret\n", "user", "secret", true},
+		{"multiline", "machine 127.0.0.1\n\tlogin user\n\tpassword secret\n", "user", "secret", true},
+		{"account ignored", "machine 127.0.0.1 login user account acct password secret\n", "user", "secret", true},
+		{"first match", "machine 127.0.0.1 login user password secret\nmachine 127.0.0.1 login other password other\n", "user", "secret", true},
+		{"default", "machine example.com login other password other\ndefault login user password secret\n", "user", "secret", true},
+		{"machine before default", "default login other password other\nmachine 127.0.0.1 login user password secret\n", "user", "secret", true},
+		{"other machine", "machine example.com login user password secret\n", "", "", false},
+		{"macdef skipped", "macdef init\nmachine 127.0.0.1 login evil password evil\n\nmachine 127.0.0.1 login user password secret\n", "user", "secret", true},
+		{"missing value", "machine 127.0.0.1 login user password", "user", "", true},
+		{"tokens without machine", "login user password secret\n", "", "", false},
+		{"empty", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				login, password, ok := r.BasicAuth()
+				if ok != tt.ok || login != tt.login || password != tt.password {
+					t.Errorf("got %q %q %t, wanted %q %q %t", login, password, ok, tt.login, tt.password, tt.ok)
+				}
+			}))
+			defer srv.Close()
+
+			path := filepath.Join(t.TempDir(), ".netrc")
+			if err := os.WriteFile(path, []byte(tt.netrc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			client, err := dlutil.NewClient(dlutil.ClientOptions{Netrc: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res.Body.Close()
+		})
+	}
+}
+
+func TestNetrcMissing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := dlutil.NewClient(dlutil.ClientOptions{Netrc: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("got no error for a missing netrc file")
+	}
+}
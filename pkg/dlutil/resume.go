@@ -0,0 +1,93 @@
+package dlutil
+
+import (
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+// partialValidatorExt is the extension of the file storing the ETag, or Last-Modified
+// date, of a partial download. A partial download without one is never resumed.
+const partialValidatorExt = ".etag"
+
+// readPartial returns the size of the partial download, and the validator to send
+// as If-Range. The size is zero if the partial download cannot be resumed.
+func readPartial(tmp string) (int64, string) {
+	info, err := os.Stat(tmp)
+	if err != nil || info.Size() == 0 {
+		return 0, ""
+	}
+
+	b, err := os.ReadFile(tmp + partialValidatorExt)
+	if err != nil || len(b) == 0 {
+		return 0, ""
+	}
+
+	return info.Size(), string(b)
+}
+
+// writePartialValidator stores the validator of the response next to the partial
+// download. Weak ETags cannot be used with If-Range, so they are not stored.
+func writePartialValidator(tmp string, res *http.Response) error {
+	validator := res.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = res.Header.Get("Last-Modified")
+	}
+
+	if validator == "" {
+		os.Remove(tmp + partialValidatorExt)
+		return nil
+	}
+
+	if err := os.WriteFile(tmp+partialValidatorExt, []byte(validator), 0o644); err != nil {
+		return errutil.New("os.WriteFile", err)
+	}
+
+	return nil
+}
+
+// removePartial removes the partial download and its validator.
+func removePartial(tmp string) {
+	os.Remove(tmp)
+	os.Remove(tmp + partialValidatorExt)
+}
+
+// hashPartial writes the first n bytes of the partial download to h.
+func hashPartial(h hash.Hash, tmp string, n int64) error {
+	file, err := os.Open(tmp)
+	if err != nil {
+		return errutil.New("os.Open", err)
+	}
+	defer file.Close()
+
+	if _, err := io.CopyN(h, file, n); err != nil {
+		return errutil.New("io.CopyN", err)
+	}
+
+	return nil
+}
+
+// contentRangeStart returns the first byte position of a 'bytes start-end/size' Content-Range.
+func contentRangeStart(res *http.Response) (int64, bool) {
+	spec, ok := strings.CutPrefix(res.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return 0, false
+	}
+
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
@@ -0,0 +1,109 @@
+package dlutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/ricochhet/pkg/errutil"
+)
+
+var (
+	ErrInvalidPublicKey     = errors.New("invalid public key")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrUnsupportedSignature = errors.New("unsupported signature algorithm")
+	ErrSignatureNoMatch     = errors.New("signature does not match any public key")
+	ErrNoPublicKeys         = errors.New("no public keys to verify signature")
+)
+
+// minisignAlgorithm is the signature algorithm of minisign legacy signatures: a pure
+// ed25519 signature over the file. Prehashed ('ED') signatures are not supported.
+const minisignAlgorithm = "Ed"
+
+// ParsePublicKey parses a base64 ed25519 public key, either raw, or in the minisign
+// format, which prefixes the key with the algorithm and a key id.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errutil.WithFramef("%w: %w", ErrInvalidPublicKey, err)
+	}
+
+	switch len(b) {
+	case ed25519.PublicKeySize:
+		return b, nil
+	case 2 + 8 + ed25519.PublicKeySize:
+		return b[10:], nil
+	default:
+		return nil, errutil.WithFramef("%w: unexpected length %d", ErrInvalidPublicKey, len(b))
+	}
+}
+
+// parseSignature parses a detached ed25519 signature. The signature may be raw bytes,
+// base64, or a minisign signature file.
+func parseSignature(data []byte) ([]byte, error) {
+	if len(data) == ed25519.SignatureSize {
+		return data, nil
+	}
+
+	for line := range strings.SplitSeq(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Contains(line, "comment:") {
+			continue
+		}
+
+		b, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, errutil.WithFramef("%w: %w", ErrInvalidSignature, err)
+		}
+
+		switch len(b) {
+		case ed25519.SignatureSize:
+			return b, nil
+		case 2 + 8 + ed25519.SignatureSize:
+			if !bytes.Equal(b[:2], []byte(minisignAlgorithm)) {
+				return nil, errutil.WithFramef("%w: %q", ErrUnsupportedSignature, b[:2])
+			}
+
+			return b[10:], nil
+		default:
+			return nil, errutil.WithFramef("%w: unexpected length %d", ErrInvalidSignature, len(b))
+		}
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+// verifySignature fetches the detached signature of the download and verifies
+// the file at path against the public keys of the receiver.
+func (d *Download) verifySignature(ctx context.Context, path string) error {
+	if len(d.PublicKeys) == 0 {
+		return errutil.WithFramef("%w: %s", ErrNoPublicKeys, d.Filename)
+	}
+
+	data, err := fetchSmall(ctx, d.Client, d.Signature)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	sig, err := parseSignature(data)
+	if err != nil {
+		return errutil.WithFrame(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return errutil.New("os.ReadFile", err)
+	}
+
+	for _, key := range d.PublicKeys {
+		if ed25519.Verify(key, b, sig) {
+			return nil
+		}
+	}
+
+	return errutil.WithFramef("%w: %s", ErrSignatureNoMatch, d.Filename)
+}
@@ -0,0 +1,135 @@
+package dlutil_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ricochhet/pkg/dlutil"
+)
+
+var keyID = []byte("01234567")
+
+// minisign returns a minisign signature file of the signature, with the algorithm.
+func minisign(algorithm string, sig []byte) []byte {
+	b := base64.StdEncoding.EncodeToString(slices.Concat([]byte(algorithm), keyID, sig))
+
+	return []byte("untrusted comment: signature\n" + b + "\ntrusted comment: gpm\nAAAA\n")
+}
+
+func TestParsePublicKey(t *testing.T) {
+	t.Parallel()
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"raw", base64.StdEncoding.EncodeToString(pub), nil},
+		{"minisign", base64.StdEncoding.EncodeToString(slices.Concat([]byte("Ed"), keyID, pub)), nil},
+		{"whitespace", " " + base64.StdEncoding.EncodeToString(pub) + "\n", nil},
+		{"not base64", "not a key!", dlutil.ErrInvalidPublicKey},
+		{"short", base64.StdEncoding.EncodeToString(pub[:16]), dlutil.ErrInvalidPublicKey},
+		{"empty", "", dlutil.ErrInvalidPublicKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := dlutil.ParsePublicKey(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, wanted %v", err, tt.err)
+			}
+
+			if tt.err == nil && !got.Equal(pub) {
+				t.Errorf("got %x, wanted %x", got, pub)
+			}
+		})
+	}
+}
+
+func TestValidatorSignature(t *testing.T) {
+	t.Parallel()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPub, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("gpm artifact")
+	sig := ed25519.Sign(priv, data)
+	sum := sha256.Sum256(data)
+
+	tests := []struct {
+		name      string
+		signature []byte
+		keys      []ed25519.PublicKey
+		sha       string
+		err       error
+	}{
+		{"raw", sig, []ed25519.PublicKey{pub}, "", nil},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), []ed25519.PublicKey{pub}, "", nil},
+		{"minisign legacy", minisign("Ed", sig), []ed25519.PublicKey{pub}, "", nil},
+		{"any key", sig, []ed25519.PublicKey{otherPub, pub}, "", nil},
+		{"with digest", sig, []ed25519.PublicKey{pub}, hex.EncodeToString(sum[:]), nil},
+		// Only legacy minisign signatures over the file itself are supported.
+		{"minisign prehashed", minisign("ED", sig), []ed25519.PublicKey{pub}, "", dlutil.ErrUnsupportedSignature},
+		{"other key", ed25519.Sign(otherPriv, data), []ed25519.PublicKey{pub}, "", dlutil.ErrSignatureNoMatch},
+		{"other file", ed25519.Sign(priv, []byte("tampered")), []ed25519.PublicKey{pub}, "", dlutil.ErrSignatureNoMatch},
+		// A file matching its digest is still rejected by its signature.
+		{"digest without signature match", ed25519.Sign(otherPriv, data), []ed25519.PublicKey{pub}, hex.EncodeToString(sum[:]), dlutil.ErrSignatureNoMatch},
+		{"no keys", sig, nil, "", dlutil.ErrNoPublicKeys},
+		{"not base64", []byte("not a signature!"), []ed25519.PublicKey{pub}, "", dlutil.ErrInvalidSignature},
+		{"short", []byte(base64.StdEncoding.EncodeToString(sig[:32])), []ed25519.PublicKey{pub}, "", dlutil.ErrInvalidSignature},
+		{"comments only", []byte("untrusted comment: signature\n"), []ed25519.PublicKey{pub}, "", dlutil.ErrInvalidSignature},
+		{"empty", nil, []ed25519.PublicKey{pub}, "", dlutil.ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(tt.signature)
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "app.tar.gz"), data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			d := dlutil.Download{
+				Directory:  dir,
+				Filename:   "app.tar.gz",
+				SHA256:     tt.sha,
+				Signature:  srv.URL + "/app.tar.gz.minisig",
+				PublicKeys: tt.keys,
+				Client:     srv.Client(),
+			}
+
+			if err := d.NewDefaultValidator(filepath.Join(dir, d.Filename)); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, wanted %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
@@ -301,6 +302,59 @@ func CopyFile(path1, path2 string) (int64, error) {
 	return io.Copy(dest, src)
 }
 
+// Size returns the size of a file, or the total size of all files within a directory.
+func Size(name string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(name, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return errutil.WithFrame(err)
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+
+	return size, err
+}
+
+// LinkOrCopy hard links path1 to path2, falling back to a copy if linking is not possible.
+func LinkOrCopy(path1, path2 string) error {
+	if err := os.MkdirAll(filepath.Dir(path2), 0o755); err != nil {
+		return errutil.New("os.MkdirAll", err)
+	}
+
+	info1, err := os.Stat(path1)
+	if err != nil {
+		return errutil.New("os.Stat", err)
+	}
+
+	// Never copy a file onto itself, as os.Create would truncate both.
+	if info2, err := os.Stat(path2); err == nil && os.SameFile(info1, info2) {
+		return nil
+	}
+
+	if err := os.Link(path1, path2); err == nil {
+		return nil
+	}
+
+	if _, err := CopyFile(path1, path2); err != nil {
+		return errutil.New("CopyFile", err)
+	}
+
+	return nil
+}
+
 // Scan scans a file and return all lines as a slice.
 func Scan(scanner *bufio.Scanner) ([]string, error) {
 	lines := []string{}
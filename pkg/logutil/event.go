@@ -0,0 +1,90 @@
+package logutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type Level string
+
+const (
+	LevelDebug Level = "debug" // Only printed in debug mode, as with Debugf.
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warn"
+	LevelError Level = "error"
+)
+
+// Fields are the structured fields of a supervisor event.
+type Fields map[string]any
+
+// Entry is a line printed in JSON format. Lines of a task have a stdout or stderr
+// stream, supervisor events have the supervisor stream, a level, an event name and
+// the fields of the event.
+type Entry struct {
+	Time    string `json:"time"`
+	Task    string `json:"task"`
+	Stream  string `json:"stream"`
+	Pid     int    `json:"pid,omitempty"`
+	Level   Level  `json:"level,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Message string `json:"message"`
+	Fields  Fields `json:"fields,omitempty"`
+}
+
+// Eventf prints a supervisor event of the task. In JSON format the event and fields
+// are included in the entry, otherwise the message is printed as with Infof.
+func (l *Logger) Eventf(level Level, event string, fields Fields, format string, a ...any) {
+	if level == LevelDebug && !debug.Load() {
+		return
+	}
+
+	if !LogJSON.Load() {
+		fmt.Fprintf(l, "["+string(level)+"] "+format, a...)
+		return
+	}
+
+	msg := fmt.Sprintf(format, a...)
+	if n := len(msg); n > 0 && msg[n-1] == '\n' {
+		msg = msg[:n-1]
+	}
+
+	l.writeEntry(Entry{
+		Stream:  "supervisor",
+		Level:   level,
+		Event:   event,
+		Message: msg,
+		Fields:  fields,
+	})
+}
+
+// writeEntry prints the entry as JSON, setting its task and pid.
+func (l *Logger) writeEntry(e Entry) {
+	l.mu.Lock()
+	e.Pid = l.pid
+	l.mu.Unlock()
+
+	e.Task = l.name
+
+	writeJSON(e)
+}
+
+// writeJSON prints the entry as JSON, setting its time. Errors are printed as text,
+// as printing them as JSON may fail the same way.
+func writeJSON(e Entry) {
+	e.Time = time.Now().Format(time.RFC3339Nano)
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[error] Failed to marshal log entry: %v\n", err)
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := out.Write(append(b, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "[error] Failed to write to buffer: %v\n", err)
+	}
+}
@@ -3,6 +3,8 @@ package logutil
 import (
 	"fmt"
 	"io"
+	"os"
+	"strings"
 	"sync/atomic"
 )
 
@@ -21,21 +23,37 @@ func IsDebug() bool {
 // Debugf prints if IsDebug is true.
 func Debugf(w io.Writer, format string, a ...any) {
 	if debug.Load() {
-		fmt.Fprintf(w, "[debug] "+format, a...)
+		logf(w, LevelDebug, format, a...)
 	}
 }
 
 // Warnf prints a warn log.
 func Warnf(w io.Writer, format string, a ...any) {
-	fmt.Fprintf(w, "[warn] "+format, a...)
+	logf(w, LevelWarn, format, a...)
 }
 
 // Errorf prints a Error log.
 func Errorf(w io.Writer, format string, a ...any) {
-	fmt.Fprintf(w, "[error] "+format, a...)
+	logf(w, LevelError, format, a...)
 }
 
 // Infof prints a Info log.
 func Infof(w io.Writer, format string, a ...any) {
-	fmt.Fprintf(w, "[info] "+format, a...)
+	logf(w, LevelInfo, format, a...)
+}
+
+// logf prints a log of the level. In JSON format, logs printed to stdout or stderr are
+// printed as supervisor entries without a task.
+func logf(w io.Writer, level Level, format string, a ...any) {
+	if LogJSON.Load() && (w == os.Stdout || w == os.Stderr) {
+		writeJSON(Entry{
+			Stream:  "supervisor",
+			Level:   level,
+			Message: strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"),
+		})
+
+		return
+	}
+
+	fmt.Fprintf(w, "["+string(level)+"] "+format, a...)
 }
@@ -0,0 +1,43 @@
+package logutil_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/ricochhet/pkg/logutil"
+)
+
+//nolint:paralleltest // Sets the global writer and format.
+func TestLevelsJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	prev := logutil.SetWriter(&buf)
+	logutil.LogJSON.Store(true)
+
+	t.Cleanup(func() {
+		logutil.SetWriter(prev)
+		logutil.LogJSON.Store(false)
+	})
+
+	logutil.Errorf(os.Stderr, "failed: %s\n", "reason")
+
+	var e logutil.Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("got %q, wanted a JSON entry: %v", buf.String(), err)
+	}
+
+	if e.Stream != "supervisor" || e.Level != logutil.LevelError || e.Message != "failed: reason" {
+		t.Errorf("got %+v, wanted an error entry of the supervisor", e)
+	}
+
+	// Logs printed to other writers, such as a task logger, are not entries.
+	var other bytes.Buffer
+
+	logutil.Infof(&other, "plain\n")
+
+	if got := other.String(); got != "[info] plain\n" {
+		t.Errorf("got %q, wanted %q", got, "[info] plain\n")
+	}
+}
@@ -5,21 +5,33 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/mattn/go-colorable"
 	"github.com/ricochhet/pkg/atomicutil"
-	"github.com/ricochhet/pkg/errutil"
 )
 
 type Logger struct {
 	idx     int
 	name    string
+	stream  string // 'stdout' or 'stderr'.
 	writes  chan []byte
 	done    chan struct{}
 	timeout time.Duration // How long to wait before printing partial lines.
 	buffers buffers       // Partial lines awaiting printing.
+
+	*state
+}
+
+// state is shared by the stdout and stderr loggers of a task.
+type state struct {
+	mu       sync.Mutex
+	matchers []matcher
+	output   Output
+	pid      int
+	stderr   *Logger
 }
 
 var Colors = []int{
@@ -32,46 +44,31 @@ var Colors = []int{
 }
 var mutex sync.Mutex
 
+const stderrColor = 31 // Red.
+
 var out = colorable.NewColorableStdout()
 
+// SetWriter sets the writer loggers print to, stdout by default, and returns the
+// previous writer.
+func SetWriter(w io.Writer) io.Writer {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	prev := out
+	out = w
+
+	return prev
+}
+
 type buffers [][]byte
 
 var (
 	LogTime           = &atomicutil.Bool{}
+	LogJSON           = &atomicutil.Bool{} // Print one JSON object per line, see Entry.
+	NoColor           = &atomicutil.Bool{} // Print without ANSI color sequences.
 	MaxProcNameLength = &atomicutil.Int32{}
 )
 
-// WriteTo writes buffer to w (io.Writer).
-func (v *buffers) WriteTo(w io.Writer) (n int64, err error) {
-	for _, b := range *v {
-		nb, err := w.Write(b)
-		n += int64(nb)
-
-		if err != nil {
-			v.consume(n)
-			return n, errutil.WithFrame(err)
-		}
-	}
-
-	v.consume(n)
-
-	return n, nil
-}
-
-// consume consumes bytes in a byte slice.
-func (v *buffers) consume(n int64) {
-	for len(*v) > 0 {
-		ln0 := int64(len((*v)[0]))
-		if ln0 > n {
-			(*v)[0] = (*v)[0][n:]
-			return
-		}
-
-		n -= ln0
-		*v = (*v)[1:]
-	}
-}
-
 // Write writes p.
 func (l *Logger) Write(p []byte) (int, error) {
 	l.writes <- p
@@ -84,27 +81,46 @@ func (l *Logger) Write(p []byte) (int, error) {
 // writeBuffers writes any stored buffers, plus the given line, then empty out
 // the buffers.
 func (l *Logger) writeBuffers(line []byte) {
-	mutex.Lock()
-	fmt.Fprintf(out, "\x1b[%dm", Colors[l.idx])
+	l.buffers = append(l.buffers, line)
+	full := bytes.Join(l.buffers, nil)
+	l.buffers = l.buffers[0:0]
+
+	defer l.match(full)
 
+	if LogJSON.Load() {
+		if !l.filtered(full) {
+			l.writeEntry(Entry{Stream: l.stream, Message: string(bytes.TrimRight(full, "\r\n"))})
+		}
+
+		return
+	}
+
+	printed, ok := l.format(full)
+	if !ok {
+		return
+	}
+
+	// Lines of stderr are marked by a red '!' separator.
+	sep := colorize(Colors[l.idx], []byte("|"), false)
+	if l.stream == "stderr" {
+		sep = colorize(stderrColor, []byte("!"), false)
+	}
+
+	var prefix string
 	if LogTime.Load() {
 		now := time.Now().Format("15:04:05")
-		fmt.Fprintf(out, "%s %*s | ", now, MaxProcNameLength.Load(), l.name)
+		prefix = fmt.Sprintf("%s %*s ", now, MaxProcNameLength.Load(), l.name)
 	} else {
-		fmt.Fprintf(out, "%*s | ", MaxProcNameLength.Load(), l.name)
+		prefix = fmt.Sprintf("%*s ", MaxProcNameLength.Load(), l.name)
 	}
 
-	fmt.Fprintf(out, "\x1b[m")
-
-	l.buffers = append(l.buffers, line)
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	if _, err := l.buffers.WriteTo(out); err != nil {
+	b := slices.Concat(colorize(Colors[l.idx], []byte(prefix), false), sep, []byte(" "), printed)
+	if _, err := out.Write(b); err != nil {
 		Errorf(os.Stderr, "Failed to write to buffer: %v\n", err)
 	}
-
-	l.buffers = l.buffers[0:0]
-
-	mutex.Unlock()
 }
 
 // writeLines bundle writes into lines, waiting briefly for completion of lines.
@@ -163,12 +179,44 @@ func CreateLogger(name string, colorIndex int) *Logger {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	return newLogger(name, colorIndex, "stdout", &state{})
+}
+
+// Stderr returns the logger of the stderr stream, which shares the options, matchers
+// and pid of the receiver.
+func (l *Logger) Stderr() *Logger {
+	if l.stream == "stderr" {
+		return l
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stderr == nil {
+		l.stderr = newLogger(l.name, l.idx, "stderr", l.state)
+	}
+
+	return l.stderr
+}
+
+// SetPid sets the pid of the process the logger writes the output of.
+func (l *Logger) SetPid(pid int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pid = pid
+}
+
+// newLogger creates a logger of the stream, and starts writing its lines.
+func newLogger(name string, colorIndex int, stream string, s *state) *Logger {
 	l := &Logger{
 		idx:     colorIndex,
 		name:    name,
+		stream:  stream,
 		writes:  make(chan []byte),
 		done:    make(chan struct{}),
 		timeout: 2 * time.Millisecond,
+		state:   s,
 	}
 	go l.writeLines()
 
@@ -0,0 +1,95 @@
+package logutil
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+)
+
+type matcher struct {
+	stream   string
+	patterns []*regexp.Regexp
+	fn       func(line string)
+}
+
+// Match calls fn with every line written to the logger that matches any of the
+// patterns. The line does not include its trailing newline. fn is called from the
+// goroutine that prints the logger's output, so it must not write to the logger.
+func (l *Logger) Match(patterns []*regexp.Regexp, fn func(line string)) {
+	l.MatchStream("", patterns, fn)
+}
+
+// MatchStream is like Match, but only matches lines of the stream, 'stdout' or
+// 'stderr'. Lines of every stream are matched if stream is empty.
+func (l *Logger) MatchStream(stream string, patterns []*regexp.Regexp, fn func(line string)) {
+	if len(patterns) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.matchers = append(l.matchers, matcher{stream: stream, patterns: patterns, fn: fn})
+}
+
+// match calls the matchers of every pattern that matches the line.
+func (l *Logger) match(line []byte) {
+	l.mu.Lock()
+	matchers := l.matchers
+	l.mu.Unlock()
+
+	if len(matchers) == 0 {
+		return
+	}
+
+	line = bytes.TrimRight(line, "\r\n")
+
+	for _, m := range matchers {
+		if m.stream != "" && m.stream != l.stream {
+			continue
+		}
+
+		for _, re := range m.patterns {
+			if re.Match(line) {
+				m.fn(string(line))
+				break
+			}
+		}
+	}
+}
+
+// Matcher returns a writer that matches the lines written to it against the matchers
+// of the logger, without printing them, e.g. for output that is redirected elsewhere.
+// A final line without a trailing newline is not matched.
+func (l *Logger) Matcher() io.Writer {
+	return &lineMatcher{logger: l}
+}
+
+// lineMatcher matches every complete line written to it, see Matcher.
+type lineMatcher struct {
+	logger *Logger
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (m *lineMatcher) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.buf = append(m.buf, p...)
+
+	for {
+		i := bytes.IndexByte(m.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		m.logger.match(m.buf[:i+1])
+		m.buf = m.buf[i+1:]
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,191 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Output configures how the lines written to a Logger are printed.
+type Output struct {
+	// JSON parses lines that are JSON objects, printing the values of Fields in order,
+	// or every field as 'key=value' if Fields is empty. Other lines are printed as is.
+	JSON   bool
+	Fields []string
+	// Level is the field of JSON lines the line is prefixed, and colored, by.
+	Level     string
+	Filter    []*regexp.Regexp // Lines matching any pattern are not printed.
+	Highlight []*regexp.Regexp // Lines matching any pattern are printed in bold.
+}
+
+// levels are the colors of common level names, and of the numeric levels used by pino
+// and bunyan.
+var levels = map[string]int{
+	"trace": 90,
+	"debug": 90,
+	"info":  32,
+	"warn":  33,
+	"error": 31,
+	"fatal": 31,
+	"panic": 31,
+}
+
+var numericLevels = map[float64]string{
+	10: "trace",
+	20: "debug",
+	30: "info",
+	40: "warn",
+	50: "error",
+	60: "fatal",
+}
+
+// SetOutput sets the output options of the logger.
+func (l *Logger) SetOutput(o Output) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.output = o
+}
+
+// format returns the line as it is printed, or false if it is filtered.
+func (l *Logger) format(line []byte) ([]byte, bool) {
+	l.mu.Lock()
+	o := l.output
+	l.mu.Unlock()
+
+	if l.filtered(line) {
+		return nil, false
+	}
+
+	text := bytes.TrimRight(line, "\r\n")
+
+	if o.JSON {
+		if formatted, ok := o.formatJSON(text); ok {
+			line = append(formatted, '\n')
+		}
+	}
+
+	for _, re := range o.Highlight {
+		if re.Match(text) {
+			return colorize(1, bytes.TrimRight(line, "\r\n"), true), true
+		}
+	}
+
+	return line, true
+}
+
+// filtered returns true if the line matches a filter pattern.
+func (l *Logger) filtered(line []byte) bool {
+	l.mu.Lock()
+	filter := l.output.Filter
+	l.mu.Unlock()
+
+	line = bytes.TrimRight(line, "\r\n")
+
+	for _, re := range filter {
+		if re.Match(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatJSON returns the fields of a JSON object line, prefixed by its level.
+func (o *Output) formatJSON(text []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(bytes.TrimSpace(text), []byte("{")) {
+		return nil, false
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(text, &fields); err != nil {
+		return nil, false
+	}
+
+	var b bytes.Buffer
+
+	if level, ok := fields[o.Level]; ok && o.Level != "" {
+		name := levelName(level)
+		b.Write(colorize(levels[name], []byte("["+name+"]"), false))
+		b.WriteByte(' ')
+		delete(fields, o.Level)
+	}
+
+	values := []string{}
+
+	if len(o.Fields) != 0 {
+		for _, key := range o.Fields {
+			if v, ok := fields[key]; ok {
+				values = append(values, formatValue(v))
+			}
+		}
+	} else {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			values = append(values, key+"="+formatValue(fields[key]))
+		}
+	}
+
+	b.WriteString(strings.Join(values, " "))
+
+	return b.Bytes(), true
+}
+
+// levelName returns the lowercase name of a string or numeric level.
+func levelName(level any) string {
+	switch v := level.(type) {
+	case string:
+		name := strings.ToLower(v)
+		if name == "warning" {
+			return "warn"
+		}
+
+		return name
+	case float64:
+		if name, ok := numericLevels[v]; ok {
+			return name
+		}
+	}
+
+	return formatValue(level)
+}
+
+// formatValue formats strings as is, and other values as JSON.
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+// colorize wraps b in the SGR code, unless NoColor is set or code is 0. If newline is
+// true, a newline is appended after the reset sequence.
+func colorize(code int, b []byte, newline bool) []byte {
+	out := slices.Clone(b)
+
+	if code != 0 && !NoColor.Load() {
+		out = slices.Concat([]byte(fmt.Sprintf("\x1b[%dm", code)), out, []byte("\x1b[m"))
+	}
+
+	if newline {
+		out = append(out, '\n')
+	}
+
+	return out
+}